@@ -76,11 +76,12 @@ func rpcClient() {
 
 	var calls [3]*rpc.Call
 	var results [3]int
-	logger.StartBroadcast("Broadcasting via RPC", options)
-	for i, client := range clients {
-		calls[i] = client.Go("Arith.Multiply", Args{i, i + 1}, &results[i], nil)
-	}
-	logger.StopBroadcast()
+	logger.Broadcast("Broadcasting via RPC", options, func(sender govec.BroadcastSender) error {
+		for i, client := range clients {
+			calls[i] = vrpc.GoBroadcast(sender, client, "Arith.Multiply", Args{i, i + 1}, &results[i], nil)
+		}
+		return nil
+	})
 
 	for i, call := range calls {
 		<-call.Done
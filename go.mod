@@ -1,9 +1,20 @@
 module github.com/DistributedClocks/GoVector
 
-go 1.11
+go 1.17
 
 require (
 	github.com/daviddengcn/go-colortext v1.0.0
 	github.com/vmihailenco/msgpack/v5 v5.1.4
+	google.golang.org/grpc v1.50.1
+	google.golang.org/protobuf v1.27.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/vmihailenco/tagparser v0.1.2 // indirect
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
+	golang.org/x/sys v0.0.0-20210119212857-b64e53b001e4 // indirect
+	golang.org/x/text v0.3.3 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
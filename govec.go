@@ -1,11 +1,13 @@
 package main
 
 import (
+	"compress/gzip"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strings"
 )
 
@@ -53,18 +55,55 @@ func write_log(logDirectory string, outputFile string, logType string) {
 	regex := get_regex(logType)
 	outf.Write([]byte(regex + "\n\n"))
 
+	var logs []string
 	for _, f := range files {
 		fname := f.Name()
-		if strings.HasSuffix(fname, "Log.txt") {
-			filepath := path.Join(logDirectory, fname)
-			content, err := ioutil.ReadFile(filepath)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			outf.Write(content)
+		if strings.HasSuffix(fname, "Log.txt") || strings.HasSuffix(fname, "Log.txt.gz") {
+			logs = append(logs, fname)
 		}
 	}
+	sort.Slice(logs, func(i, j int) bool {
+		return segment_key(logs[i]) < segment_key(logs[j])
+	})
+
+	for _, fname := range logs {
+		filepath := path.Join(logDirectory, fname)
+		content, err := read_log(filepath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		outf.Write(content)
+	}
+}
+
+// segment_key orders the closed segments of a rotated log, such as
+// "node.000001-Log.txt.gz", before the log file still being written,
+// "node-Log.txt", so that the events of a process are merged in order
+func segment_key(fname string) string {
+	fname = strings.TrimSuffix(fname, ".gz")
+	fname = strings.TrimSuffix(fname, "Log.txt")
+	fname = strings.TrimSuffix(fname, "-")
+	return fname + "\x7f"
+}
+
+// read_log returns the content of a log file, decompressing segments
+// compressed with gzip
+func read_log(filepath string) ([]byte, error) {
+	if !strings.HasSuffix(filepath, ".gz") {
+		return ioutil.ReadFile(filepath)
+	}
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
 }
 
 func main() {
@@ -0,0 +1,204 @@
+package govec
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what happens to a log entry when the queue of
+// an asynchronous GoLog is full.
+type OverflowPolicy int
+
+const (
+	// Block makes the logging call wait until the queue has room
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest queued entry to make room
+	DropOldest
+	// DropNewest discards the new entry, the logging call reporting a
+	// failure
+	DropNewest
+)
+
+// AsyncPolicy controls the asynchronous writing of log entries. The
+// zero AsyncPolicy writes entries synchronously, while the event is
+// being logged.
+type AsyncPolicy struct {
+	// QueueSize is the number of entries waiting to be written by the
+	// background writer, 0 to write synchronously
+	QueueSize int
+	// Overflow decides what happens when the queue is full
+	Overflow OverflowPolicy
+	// FlushInterval, if set, makes the background writer write entries
+	// in batches of at most QueueSize entries, at least every
+	// FlushInterval, instead of one by one as they are queued
+	FlushInterval time.Duration
+}
+
+// asyncWriter writes log entries to sinks from a background goroutine.
+// Entries are pushed by the GoLog with its lock held.
+type asyncWriter struct {
+	queue    chan []byte
+	overflow OverflowPolicy
+	interval time.Duration
+	sinks    []Sink
+	// flushes carries the requests of Flush, answered once every entry
+	// queued before the request is written
+	flushes chan chan bool
+	// done is closed once the writer has stopped and closed the sinks
+	done chan struct{}
+	// dropped counts the entries discarded on overflow
+	dropped uint64
+	// failed is set to 1 when a sink fails, and reset by Flush
+	failed int32
+	// closeErr is the error returned by closing the sinks
+	closeErr error
+}
+
+func newAsyncWriter(policy AsyncPolicy, sinks []Sink) *asyncWriter {
+	w := &asyncWriter{
+		queue:    make(chan []byte, policy.QueueSize),
+		overflow: policy.Overflow,
+		interval: policy.FlushInterval,
+		sinks:    sinks,
+		flushes:  make(chan chan bool),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// push queues entry, false is returned if it was dropped
+func (w *asyncWriter) push(entry []byte) bool {
+	switch w.overflow {
+	case DropNewest:
+		select {
+		case w.queue <- entry:
+			return true
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+			return false
+		}
+	case DropOldest:
+		for {
+			select {
+			case w.queue <- entry:
+				return true
+			default:
+			}
+			select {
+			case <-w.queue:
+				atomic.AddUint64(&w.dropped, 1)
+			default:
+			}
+		}
+	default:
+		w.queue <- entry
+		return true
+	}
+}
+
+// flush waits until every queued entry is written, and reports
+// whether every entry was written since the previous call
+func (w *asyncWriter) flush() bool {
+	reply := make(chan bool)
+	select {
+	case w.flushes <- reply:
+		return <-reply
+	case <-w.done:
+		return atomic.SwapInt32(&w.failed, 0) == 0
+	}
+}
+
+// close stops the writer once every queued entry is written. No entry
+// may be pushed afterwards.
+func (w *asyncWriter) close() {
+	close(w.queue)
+}
+
+// wait waits for the writer to stop, and returns the error of closing
+// the sinks.
+func (w *asyncWriter) wait(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return w.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var batch [][]byte
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				w.write(batch)
+				w.closeSinks()
+				return
+			}
+			batch = append(batch, entry)
+			if w.interval == 0 || len(batch) >= cap(w.queue) {
+				w.write(batch)
+				batch = nil
+			}
+		case <-tick:
+			w.write(batch)
+			batch = nil
+		case reply := <-w.flushes:
+			for drained := false; !drained; {
+				select {
+				case entry, ok := <-w.queue:
+					if ok {
+						batch = append(batch, entry)
+					} else {
+						drained = true
+					}
+				default:
+					drained = true
+				}
+			}
+			w.write(batch)
+			batch = nil
+			reply <- atomic.SwapInt32(&w.failed, 0) == 0
+		}
+	}
+}
+
+func (w *asyncWriter) write(batch [][]byte) {
+	if len(w.sinks) == 0 && len(batch) > 0 {
+		atomic.StoreInt32(&w.failed, 1)
+	}
+	for _, entry := range batch {
+		for _, sink := range w.sinks {
+			if _, err := sink.Write(entry); err != nil {
+				atomic.StoreInt32(&w.failed, 1)
+			}
+		}
+	}
+}
+
+func (w *asyncWriter) closeSinks() {
+	for _, sink := range w.sinks {
+		if err := sink.Close(); err != nil && w.closeErr == nil {
+			w.closeErr = err
+		}
+	}
+}
+
+// DroppedEvents returns the number of log entries discarded because
+// the queue of an asynchronous GoLog was full. See AsyncPolicy.
+func (gv *GoLog) DroppedEvents() uint64 {
+	if gv.async == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&gv.async.dropped)
+}
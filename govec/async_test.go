@@ -0,0 +1,138 @@
+package govec
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink is a RingSink whose writes block until released
+type blockingSink struct {
+	*RingSink
+	writing chan bool
+	release chan bool
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{RingSink: NewRingSink(64), writing: make(chan bool, 64), release: make(chan bool)}
+}
+
+func (s *blockingSink) Write(entry []byte) (int, error) {
+	s.writing <- true
+	<-s.release
+	return s.RingSink.Write(entry)
+}
+
+func newAsyncGoLog(sink Sink, policy AsyncPolicy) *GoLog {
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{sink}
+	config.Async = policy
+	return InitGoVector(TestPID, "TestLogFile", config)
+}
+
+func messages(entries []string) string {
+	var m []string
+	for _, entry := range entries {
+		lines := strings.Split(strings.TrimSuffix(entry, "\n"), "\n")
+		m = append(m, strings.TrimPrefix(lines[len(lines)-1], "INFO "))
+	}
+	return strings.Join(m, " ")
+}
+
+func TestAsyncWriter(t *testing.T) {
+
+	ring := NewRingSink(128)
+	gv := newAsyncGoLog(ring, AsyncPolicy{QueueSize: 4})
+	opts := GetDefaultLogOptions()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				gv.LogLocalEvent("TestEvent", opts)
+			}
+		}()
+	}
+	wg.Wait()
+	AssertTrue(t, gv.Flush(), "Async: Flush failed")
+	AssertEquals(t, 101, len(ring.Entries()), "Async: entries not written by Flush")
+	AssertEquals(t, uint64(0), gv.DroppedEvents(), "Async: entries dropped while blocking")
+
+	// Entries are written in the order of their clocks
+	for i, entry := range ring.Entries() {
+		AssertTrue(t, strings.Contains(entry, `{"TestPID":`+strconv.Itoa(i+1)+`}`), "Async: entries out of order")
+	}
+
+	AssertTrue(t, gv.CloseContext(context.Background()) == nil, "Async: CloseContext failed")
+	AssertTrue(t, !gv.LogLocalEvent("TestEvent", opts), "Async: event logged after CloseContext")
+	AssertEquals(t, 102, len(ring.Entries()), "Async: entry written after CloseContext")
+}
+
+func TestAsyncOverflow(t *testing.T) {
+
+	expected := map[OverflowPolicy]string{
+		DropNewest: "Initialization Complete 1 2 3",
+		DropOldest: "Initialization Complete 1 4 5",
+	}
+	for overflow, written := range expected {
+		sink := newBlockingSink()
+		// The initialization event is written before the writer starts
+		go func() { <-sink.writing; sink.release <- true }()
+		gv := newAsyncGoLog(sink, AsyncPolicy{QueueSize: 2, Overflow: overflow})
+		opts := GetDefaultLogOptions()
+
+		// The writer blocks on the first event, the next two fill the
+		// queue
+		gv.LogLocalEvent("1", opts)
+		<-sink.writing
+		AssertTrue(t, gv.LogLocalEvent("2", opts), "Async: event dropped")
+		AssertTrue(t, gv.LogLocalEvent("3", opts), "Async: event dropped")
+		AssertEquals(t, overflow == DropOldest, gv.LogLocalEvent("4", opts), "Async: wrong overflow result")
+		AssertEquals(t, overflow == DropOldest, gv.LogLocalEvent("5", opts), "Async: wrong overflow result")
+		AssertEquals(t, uint64(2), gv.DroppedEvents(), "Async: wrong dropped count")
+
+		close(sink.release)
+		AssertTrue(t, gv.Flush(), "Async: Flush failed")
+		AssertEquals(t, written, messages(sink.Entries()), "Async: wrong entries written")
+	}
+}
+
+func TestAsyncFlushInterval(t *testing.T) {
+
+	ring := NewRingSink(8)
+	gv := newAsyncGoLog(ring, AsyncPolicy{QueueSize: 8, FlushInterval: 10 * time.Millisecond})
+	opts := GetDefaultLogOptions()
+	gv.LogLocalEvent("1", opts)
+	gv.LogLocalEvent("2", opts)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ring.Entries()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	AssertEquals(t, "Initialization Complete 1 2", messages(ring.Entries()), "Async: batch not written")
+}
+
+func TestAsyncCloseTimeout(t *testing.T) {
+
+	sink := newBlockingSink()
+	go func() { <-sink.writing; sink.release <- true }()
+	gv := newAsyncGoLog(sink, AsyncPolicy{QueueSize: 2})
+	gv.LogLocalEvent("1", GetDefaultLogOptions())
+	<-sink.writing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	AssertEquals(t, context.DeadlineExceeded, gv.CloseContext(ctx), "Async: CloseContext did not time out")
+
+	// The queue is still drained in the background
+	close(sink.release)
+	AssertTrue(t, gv.CloseContext(context.Background()) == nil, "Async: second CloseContext failed")
+	AssertTrue(t, gv.async.wait(context.Background()) == nil, "Async: writer not stopped")
+	AssertEquals(t, "Initialization Complete 1 Process terminated", messages(sink.Entries()), "Async: queue not drained")
+}
@@ -0,0 +1,145 @@
+package govec
+
+import (
+	"sync"
+)
+
+// BroadcastSender encodes the messages of a broadcast. Every message
+// carries the clock of the single send event logged for the broadcast.
+// A BroadcastSender is safe for concurrent use, and is only valid until
+// the function it was passed to returns, or until StopBroadcast for one
+// returned by StartBroadcast.
+type BroadcastSender interface {
+	// PrepareSend encodes buf, like GoLog.PrepareSendE, without
+	// ticking the clock nor logging an event
+	PrepareSend(buf interface{}) ([]byte, error)
+}
+
+// broadcastSender implements BroadcastSender with a copy of the clock
+// taken when the broadcast was logged
+type broadcastSender struct {
+	gv      *GoLog
+	payload func(buf interface{}) interface{}
+	mutex   sync.Mutex
+	done    bool
+}
+
+func (b *broadcastSender) PrepareSend(buf interface{}) ([]byte, error) {
+	b.mutex.Lock()
+	done := b.done
+	b.mutex.Unlock()
+	if done {
+		return nil, newError(ErrBroadcastDone, nil)
+	}
+	if b.payload == nil {
+		// The broadcast was filtered out by its priority
+		return nil, nil
+	}
+	return b.gv.encodeBroadcast(b.payload, buf)
+}
+
+func (b *broadcastSender) close() {
+	b.mutex.Lock()
+	b.done = true
+	b.mutex.Unlock()
+}
+
+// Broadcast logs a single send event with mesg and calls send with a
+// BroadcastSender encoding any number of messages, all carrying the
+// clock of that event, so that they represent one message broadcast
+// from the current process to the process pool. The GoLog is not
+// locked while send runs: other goroutines may log events and send
+// messages of their own, and send may use the GoLog too. The error
+// returned by send is returned, or else an error of kind ErrLogWrite
+// if the send event could not be logged. As with PrepareSendE, nothing
+// is logged and the messages are nil if the priority of opts is below
+// that of the GoLog.
+func (gv *GoLog) Broadcast(mesg string, opts GoLogOptions, send func(BroadcastSender) error) error {
+	gv.mutex.Lock()
+	b, err := gv.startBroadcast(mesg, opts)
+	gv.mutex.Unlock()
+	defer b.close()
+
+	if sendErr := send(b); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// startBroadcast logs the send event of a broadcast and returns its
+// sender. It must be called with the lock held.
+func (gv *GoLog) startBroadcast(mesg string, opts GoLogOptions) (*broadcastSender, error) {
+	b := &broadcastSender{gv: gv}
+	if opts.Priority < gv.priority {
+		return b, nil
+	}
+	gv.tickClock()
+	b.payload = gv.payloadFunc()
+	if !gv.logWriteWrapper(mesg, "Something went wrong, could not log prepare send", SendEvent, opts) && gv.logging {
+		return b, newError(ErrLogWrite, nil)
+	}
+	return b, nil
+}
+
+// payloadFunc returns a function wrapping payloads along with a copy
+// of the current clock. It must be called with the lock held.
+func (gv *GoLog) payloadFunc() func(buf interface{}) interface{} {
+	if gv.clock != nil {
+		envelope := gv.clock.envelope(gv.pid, nil)
+		return func(buf interface{}) interface{} {
+			return withPayload(envelope, buf)
+		}
+	}
+	vc := gv.currentVC.Copy()
+	pid := gv.pid
+	return func(buf interface{}) interface{} {
+		return &VClockPayload{Pid: pid, VcMap: vc.GetMap(), Payload: buf}
+	}
+}
+
+// encodeBroadcast encodes buf wrapped by payload with the configured
+// encoding strategy.
+func (gv *GoLog) encodeBroadcast(payload func(buf interface{}) interface{}, buf interface{}) ([]byte, error) {
+	encodedBytes, err := gv.encodingStrategy(payload(buf))
+	if err != nil {
+		return nil, newError(ErrEncode, err)
+	}
+	return encodedBytes, nil
+}
+
+// StartBroadcast allows to use vector clocks in the context of casual
+// broadcasts sent via RPC. It logs a single send event and returns a
+// BroadcastSender encoding messages which carry the clock of that
+// event, see vrpc.GoBroadcast, until the matching call to
+// StopBroadcast. Only one such broadcast runs at a time:
+// StartBroadcast blocks until the previous one is stopped, but the
+// GoLog itself is not locked in-between. Broadcast should be
+// preferred, as it cannot be left running.
+//
+// Messages encoded by PrepareSend and the like in-between are part of
+// the broadcast too, as in earlier versions, whichever goroutine
+// encodes them. This use is deprecated: encode the messages of the
+// broadcast with the returned BroadcastSender instead.
+func (gv *GoLog) StartBroadcast(mesg string, opts GoLogOptions) BroadcastSender {
+	gv.broadcastMutex.Lock()
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	gv.broadcast, _ = gv.startBroadcast(mesg, opts)
+	return gv.broadcast
+}
+
+// StopBroadcast is called once all RPC calls of a message broadcast
+// started by StartBroadcast have been sent. The BroadcastSender it
+// returned is no longer valid. StopBroadcast does nothing if no
+// broadcast was started.
+func (gv *GoLog) StopBroadcast() {
+	gv.mutex.Lock()
+	if gv.broadcast == nil {
+		gv.mutex.Unlock()
+		return
+	}
+	gv.broadcast.close()
+	gv.broadcast = nil
+	gv.mutex.Unlock()
+	gv.broadcastMutex.Unlock()
+}
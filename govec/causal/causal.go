@@ -0,0 +1,175 @@
+// Package causal delivers broadcast messages in causal order. A
+// message is held back on receipt until every message broadcast before
+// it, by any process, has been delivered, so that no process ever sees
+// an effect before its cause.
+//
+// The broadcast clock used to that end counts messages broadcast by
+// every process, and is carried in a govec.VClockPayload of its own.
+// It differs from the clock of a GoLog, which counts every event and
+// cannot tell whether a message is missing. Broadcaster sends this
+// payload through a GoLog, which logs the receipt of every message as
+// it arrives and its delivery once it is released.
+//
+// See Birman, Schiper and Stephenson, "Lightweight Causal and Atomic
+// Group Multicast" (1991).
+package causal
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// ErrFiltered is returned when broadcasting or receiving a message
+// with a priority the GoLog filters out, see govec.GoLog.Enabled. Such
+// a message carries no clock and cannot be delivered in causal order,
+// so broadcasts must be sent and received with a priority the GoLog
+// logs.
+var ErrFiltered = errors.New("causal: message filtered out by the priority of the GoLog")
+
+// Queue is a hold-back queue for causal broadcast. A Queue is not safe
+// for concurrent use.
+type Queue struct {
+	pid string
+	// delivered counts the messages of every process delivered so far
+	delivered vclock.VClock
+	pending   []govec.VClockPayload
+}
+
+// NewQueue returns the hold-back queue of process pid.
+func NewQueue(pid string) *Queue {
+	return &Queue{pid: pid, delivered: vclock.VClock{pid: 0}}
+}
+
+// Stamp returns the broadcast clock of a new message broadcast by the
+// local process, which delivers its own message right away.
+func (q *Queue) Stamp() vclock.VClock {
+	q.delivered.Tick(q.pid)
+	return q.delivered.Copy()
+}
+
+// Delivered returns the broadcast clock of the messages delivered so
+// far.
+func (q *Queue) Delivered() vclock.VClock {
+	return q.delivered.Copy()
+}
+
+// Pending returns the number of messages held back.
+func (q *Queue) Pending() int {
+	return len(q.pending)
+}
+
+// deliverable reports whether every message broadcast before m has
+// been delivered: m is the next message of its sender, and its sender
+// had not delivered more messages of other processes than the local
+// process.
+func (q *Queue) deliverable(m govec.VClockPayload) bool {
+	for id, ticks := range m.VcMap {
+		if id == m.Pid {
+			if ticks != q.delivered[id]+1 {
+				return false
+			}
+		} else if ticks > q.delivered[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Add holds m, a message received from another process whose VcMap is
+// the broadcast clock returned by Stamp, back and returns the messages
+// which can now be delivered, in causal order. Messages which were
+// already delivered are dropped.
+func (q *Queue) Add(m govec.VClockPayload) []govec.VClockPayload {
+	if m.VcMap[m.Pid] <= q.delivered[m.Pid] {
+		return nil
+	}
+	q.pending = append(q.pending, m)
+
+	var released []govec.VClockPayload
+	for progress := true; progress; {
+		progress = false
+		for i := 0; i < len(q.pending); i++ {
+			if m := q.pending[i]; q.deliverable(m) {
+				q.delivered.Tick(m.Pid)
+				released = append(released, m)
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				progress = true
+				i--
+			}
+		}
+	}
+	return released
+}
+
+// Broadcaster sends and delivers causal broadcasts through a GoLog. A
+// Broadcaster is safe for concurrent use.
+type Broadcaster struct {
+	gv    *govec.GoLog
+	queue *Queue
+	mutex sync.Mutex
+}
+
+// NewBroadcaster returns a Broadcaster for process pid, logging to gv.
+func NewBroadcaster(pid string, gv *govec.GoLog) *Broadcaster {
+	return &Broadcaster{gv: gv, queue: NewQueue(pid)}
+}
+
+// PrepareBroadcast encodes buf as a new broadcast message to be sent
+// to every other process, and logs a send event. The message is
+// considered delivered by the local process. If opts has a priority
+// the GoLog filters out, ErrFiltered is returned and nothing is to be
+// sent.
+func (b *Broadcaster) PrepareBroadcast(mesg string, buf interface{}, opts govec.GoLogOptions) ([]byte, error) {
+	if !b.gv.Enabled(opts.Priority) {
+		return nil, ErrFiltered
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	stamp := b.queue.Stamp()
+	encoded, err := b.gv.PrepareSendE(mesg, &govec.VClockPayload{Pid: b.queue.pid, VcMap: stamp, Payload: buf}, opts)
+	if encoded == nil || (err != nil && !errors.Is(err, govec.ErrLogWrite)) {
+		// Nothing was sent, take the message back
+		b.queue.delivered.Set(b.queue.pid, stamp[b.queue.pid]-1)
+	}
+	return encoded, err
+}
+
+// Receive decodes a broadcast message encoded by PrepareBroadcast into
+// unpack, logs its receipt with mesg, and returns the messages which
+// can now be delivered in causal order, the payload of each being the
+// unpack it was received with. The delivery of every message is logged
+// as a local event. A fresh unpack must thus be passed to every call.
+// If opts has a priority the GoLog filters out, the message is not
+// decoded and ErrFiltered is returned.
+func (b *Broadcaster) Receive(mesg string, buf []byte, unpack interface{}, opts govec.GoLogOptions) ([]govec.VClockPayload, error) {
+	if !b.gv.Enabled(opts.Priority) {
+		return nil, ErrFiltered
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	m := govec.VClockPayload{Payload: unpack}
+	err := b.gv.UnpackReceiveE(mesg, buf, &m, opts)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return nil, err
+	}
+	if m.Pid == "" {
+		return nil, errors.New("causal: message is missing the broadcaster's process id")
+	}
+
+	released := b.queue.Add(m)
+	for _, r := range released {
+		deliverOpts := opts.SetAttr("from", r.Pid)
+		b.gv.LogLocalEvent("Delivering broadcast message", deliverOpts)
+	}
+	return released, err
+}
+
+// Pending returns the number of messages held back.
+func (b *Broadcaster) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.queue.Pending()
+}
@@ -0,0 +1,196 @@
+package causal
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestQueueHoldsBack(t *testing.T) {
+
+	a, b, c := NewQueue("a"), NewQueue("b"), NewQueue("c")
+
+	// a broadcasts m1, b delivers it and broadcasts m2
+	m1 := govec.VClockPayload{Pid: "a", VcMap: a.Stamp(), Payload: "m1"}
+	AssertEquals(t, 1, len(b.Add(m1)), "Add: m1 not delivered")
+	m2 := govec.VClockPayload{Pid: "b", VcMap: b.Stamp(), Payload: "m2"}
+
+	// c receives m2 before m1
+	AssertEquals(t, 0, len(c.Add(m2)), "Add: m2 delivered before m1")
+	AssertEquals(t, 1, c.Pending(), "Pending: m2 not held back")
+	released := c.Add(m1)
+	AssertEquals(t, 2, len(released), "Add: held back message not released")
+	AssertEquals(t, "m1", released[0].Payload, "Add: wrong delivery order")
+	AssertEquals(t, "m2", released[1].Payload, "Add: wrong delivery order")
+
+	// Duplicates are dropped
+	AssertEquals(t, 0, len(c.Add(m1)), "Add: duplicate delivered")
+	AssertEquals(t, `{"a":1, "b":1, "c":0}`, c.Delivered().ReturnVCString(), "Delivered: wrong clock")
+}
+
+// packet is a message in flight in the simulated network
+type packet struct {
+	to   int
+	data []byte
+	// id identifies the broadcast message
+	id int
+}
+
+// network simulates processes broadcasting over a network which
+// delivers packets in an order picked by a seeded random source, and
+// records the causal past of every message by hand to check delivery
+// order.
+type network struct {
+	t        *testing.T
+	r        *rand.Rand
+	procs    []*Broadcaster
+	rings    []*govec.RingSink
+	inflight []packet
+	// past[id] holds the messages delivered by the broadcaster of id
+	// before it broadcast id
+	past []map[int]bool
+	// seen[i] holds the messages delivered by process i
+	seen []map[int]bool
+	// order[i] lists the messages delivered by process i
+	order [][]int
+}
+
+func newNetwork(t *testing.T, seed int64, n int) *network {
+	net := &network{t: t, r: rand.New(rand.NewSource(seed))}
+	for i := 0; i < n; i++ {
+		ring := govec.NewRingSink(1024)
+		config := govec.GetDefaultConfig()
+		config.LogToFile = false
+		config.Sinks = []govec.Sink{ring}
+		pid := fmt.Sprintf("p%d", i)
+		net.procs = append(net.procs, NewBroadcaster(pid, govec.InitGoVector(pid, "TestLogFile", config)))
+		net.rings = append(net.rings, ring)
+		net.seen = append(net.seen, map[int]bool{})
+		net.order = append(net.order, nil)
+	}
+	return net
+}
+
+func (net *network) broadcast(from int) {
+	id := len(net.past)
+	past := map[int]bool{}
+	for m := range net.seen[from] {
+		past[m] = true
+	}
+	net.past = append(net.past, past)
+	net.seen[from][id] = true
+	net.order[from] = append(net.order[from], id)
+
+	data, err := net.procs[from].PrepareBroadcast("Broadcasting", id, govec.GetDefaultLogOptions())
+	AssertTrue(net.t, err == nil, "PrepareBroadcast failed")
+	for to := range net.procs {
+		if to != from {
+			net.inflight = append(net.inflight, packet{to: to, data: data, id: id})
+		}
+	}
+}
+
+// step delivers a random packet in flight
+func (net *network) step() {
+	k := net.r.Intn(len(net.inflight))
+	p := net.inflight[k]
+	net.inflight = append(net.inflight[:k], net.inflight[k+1:]...)
+
+	var id int
+	released, err := net.procs[p.to].Receive("Receiving", p.data, &id, govec.GetDefaultLogOptions())
+	AssertTrue(net.t, err == nil, "Receive failed")
+	for _, m := range released {
+		delivered := *m.Payload.(*int)
+		for cause := range net.past[delivered] {
+			if !net.seen[p.to][cause] {
+				net.t.Fatalf("p%d delivered message %d before its cause %d", p.to, delivered, cause)
+			}
+		}
+		net.seen[p.to][delivered] = true
+		net.order[p.to] = append(net.order[p.to], delivered)
+	}
+}
+
+func TestCausalDelivery(t *testing.T) {
+
+	for seed := int64(0); seed < 20; seed++ {
+		net := newNetwork(t, seed, 4)
+		broadcasts := 0
+		for broadcasts < 30 || len(net.inflight) > 0 {
+			if broadcasts < 30 && (len(net.inflight) == 0 || net.r.Intn(3) == 0) {
+				net.broadcast(net.r.Intn(len(net.procs)))
+				broadcasts++
+			} else {
+				net.step()
+			}
+		}
+
+		for i, b := range net.procs {
+			AssertEquals(t, 0, b.Pending(), "Pending: messages left in the hold-back queue")
+			AssertEquals(t, broadcasts, len(net.order[i]), "Delivery: messages lost")
+		}
+	}
+}
+
+func TestDeliveryLogged(t *testing.T) {
+
+	net := newNetwork(t, 1, 2)
+	net.broadcast(0)
+	net.step()
+
+	entries := net.rings[1].Entries()
+	AssertEquals(t, 3, len(entries), "Log: expected initialization, receive and deliver events")
+	AssertTrue(t, strings.HasSuffix(entries[1], "INFO Receiving\n"), "Log: receive event not logged")
+	AssertTrue(t, strings.HasSuffix(entries[2], "INFO Delivering broadcast message from=p0\n"), "Log: deliver event not logged")
+
+	// The delivery follows the receipt in the GoLog clock
+	header := strings.SplitN(entries[2], "\n", 2)[0]
+	vc, err := vclock.Parse(header[strings.Index(header, " ")+1:])
+	AssertTrue(t, err == nil, "Log: clock does not parse")
+	AssertEquals(t, `{"p0":2, "p1":3}`, vc.ReturnVCString(), "Log: wrong deliver clock")
+}
+
+func TestFilteredPriority(t *testing.T) {
+
+	net := newNetwork(t, 1, 2)
+	debug := govec.GetDefaultLogOptions()
+	debug.Priority = govec.DEBUG
+	config := govec.GetDefaultConfig()
+	config.LogToFile = false
+	config.Priority = govec.INFO
+	net.procs[0] = NewBroadcaster("p0", govec.InitGoVector("p0", "TestLogFile", config))
+
+	data, err := net.procs[0].PrepareBroadcast("Broadcasting", 0, debug)
+	AssertEquals(t, ErrFiltered, err, "PrepareBroadcast: filtered message not reported")
+	AssertTrue(t, data == nil, "PrepareBroadcast: filtered message encoded")
+	AssertEquals(t, uint64(0), net.procs[0].queue.Delivered()["p0"], "PrepareBroadcast: filtered message stamped")
+
+	// The next broadcast is not held back waiting for the filtered one
+	net.broadcast(0)
+	net.step()
+	AssertEquals(t, 0, net.procs[1].Pending(), "Receive: broadcast held back after a filtered one")
+	AssertEquals(t, 1, len(net.order[1]), "Receive: broadcast not delivered")
+
+	net.procs[1] = NewBroadcaster("p1", govec.InitGoVector("p1", "TestLogFile", config))
+	data, err = net.procs[0].PrepareBroadcast("Broadcasting", 1, govec.GetDefaultLogOptions())
+	AssertTrue(t, err == nil, "PrepareBroadcast failed")
+	var id int
+	_, err = net.procs[1].Receive("Receiving", data, &id, debug)
+	AssertEquals(t, ErrFiltered, err, "Receive: filtered message not reported")
+}
+
+func AssertTrue(t *testing.T, condition bool, message string) {
+	if !condition {
+		t.Fatalf(message)
+	}
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+" Expected: %v, Actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,296 @@
+package govec
+
+import (
+	"github.com/DistributedClocks/GoVector/govec/hlc"
+	"github.com/DistributedClocks/GoVector/govec/itc"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ClockType selects the logical clock a GoLog sends along with its
+// messages.
+type ClockType int
+
+// ClockType enum provides the clocks GoLog can maintain.
+const (
+	// VectorClock sends the full vector clock along with every
+	// message. This is the default.
+	VectorClock ClockType = iota
+	// HybridLogicalClock sends a Hybrid Logical Clock timestamp along
+	// with every message, whose size does not depend on the number of
+	// processes. The vector clock of the log then only counts the
+	// local events of the process, and the physical component of the
+	// hybrid clock is logged as the timestamp of every event for
+	// TSViz.
+	HybridLogicalClock
+	// IntervalTreeClock sends an Interval Tree Clock stamp along with
+	// every message, whose size follows the number of live processes
+	// rather than of every process ever seen. The first process starts
+	// with the seed stamp, itc.Seed(), and every other one with a stamp
+	// forked by GoLog.ForkStamp, see GoLogConfig.InitialStamp. The
+	// vector clock of the log then only counts the local events of the
+	// process and every event is logged with its stamp as the itc
+	// attribute, which itc.VClocks turns into vector clocks ShiViz can
+	// render.
+	IntervalTreeClock
+)
+
+// altClock is a logical clock sent by GoLog in place of its vector
+// clock. The vector clock keeps counting local events so that the log
+// remains readable by ShiViz.
+type altClock interface {
+	// tick advances the clock for a local or send event
+	tick()
+	// envelope returns the wire payload sending buf from pid along
+	// with the clock
+	envelope(pid string, buf interface{}) interface{}
+	// newEnvelope returns an empty wire payload to decode a message
+	// carrying unpack into
+	newEnvelope(unpack interface{}) interface{}
+	// receive advances the clock for the receipt of a decoded wire
+	// payload, false is returned if the payload does not name its
+	// sender
+	receive(envelope interface{}) bool
+	// annotate stamps a logged event with the clock
+	annotate(e *Event)
+}
+
+// newAltClock returns the clock selected by config, or nil for
+// VectorClock
+func newAltClock(config GoLogConfig) altClock {
+	switch config.Clock {
+	case HybridLogicalClock:
+		return &hybridClock{clock: hlc.New(nil)}
+	case IntervalTreeClock:
+		return &treeClock{stamp: config.InitialStamp}
+	default:
+		return nil
+	}
+}
+
+// withPayload returns a copy of a wire payload returned by
+// altClock.envelope carrying buf instead
+func withPayload(envelope interface{}, buf interface{}) interface{} {
+	switch d := envelope.(type) {
+	case *HLCPayload:
+		c := *d
+		c.Payload = buf
+		return &c
+	case *ITCPayload:
+		c := *d
+		c.Payload = buf
+		return &c
+	default:
+		panic("govec: unknown clock payload")
+	}
+}
+
+// HLCPayload is the data structure sent on the wire in place of
+// VClockPayload by GoLogs using HybridLogicalClock.
+type HLCPayload struct {
+	Pid     string
+	Wall    int64
+	Logical uint32
+	Payload interface{}
+}
+
+// EncodeMsgpack is a custom encoder function, needed for msgpack interoperability
+func (d *HLCPayload) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeString(d.Pid); err != nil {
+		return err
+	}
+	if err := enc.Encode(d.Payload); err != nil {
+		return err
+	}
+	if err := enc.EncodeInt(d.Wall); err != nil {
+		return err
+	}
+	return enc.EncodeUint(uint64(d.Logical))
+}
+
+// DecodeMsgpack is a custom decoder function, needed for msgpack
+// interoperability
+func (d *HLCPayload) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var err error
+	if d.Pid, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	if err = dec.Decode(&d.Payload); err != nil {
+		return err
+	}
+	if d.Wall, err = dec.DecodeInt64(); err != nil {
+		return err
+	}
+	d.Logical, err = dec.DecodeUint32()
+	return err
+}
+
+// hybridClock implements HybridLogicalClock
+type hybridClock struct {
+	clock *hlc.Clock
+}
+
+func (c *hybridClock) tick() {
+	c.clock.Now()
+}
+
+func (c *hybridClock) envelope(pid string, buf interface{}) interface{} {
+	ts := c.clock.Last()
+	return &HLCPayload{Pid: pid, Wall: ts.Wall, Logical: ts.Logical, Payload: buf}
+}
+
+func (c *hybridClock) newEnvelope(unpack interface{}) interface{} {
+	return &HLCPayload{Payload: unpack}
+}
+
+func (c *hybridClock) receive(envelope interface{}) bool {
+	d := envelope.(*HLCPayload)
+	if d.Pid == "" {
+		return false
+	}
+	c.clock.Update(hlc.Timestamp{Wall: d.Wall, Logical: d.Logical})
+	return true
+}
+
+func (c *hybridClock) annotate(e *Event) {
+	ts := c.clock.Last()
+	e.Time = ts.Time()
+	e.Attrs = append(append([]Attr(nil), e.Attrs...), Attr{Key: "hlc", Value: ts.String()})
+}
+
+// ITCPayload is the data structure sent on the wire in place of
+// VClockPayload by GoLogs using IntervalTreeClock. Stamp is anonymous,
+// it only carries the events known by the sender.
+type ITCPayload struct {
+	Pid     string
+	Stamp   itc.Stamp
+	Payload interface{}
+}
+
+// EncodeMsgpack is a custom encoder function, needed for msgpack interoperability
+func (d *ITCPayload) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeString(d.Pid); err != nil {
+		return err
+	}
+	if err := enc.Encode(d.Payload); err != nil {
+		return err
+	}
+	stamp, err := d.Stamp.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return enc.EncodeBytes(stamp)
+}
+
+// DecodeMsgpack is a custom decoder function, needed for msgpack
+// interoperability
+func (d *ITCPayload) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var err error
+	if d.Pid, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	if err = dec.Decode(&d.Payload); err != nil {
+		return err
+	}
+	stamp, err := dec.DecodeBytes()
+	if err != nil {
+		return err
+	}
+	return d.Stamp.UnmarshalBinary(stamp)
+}
+
+// treeClock implements IntervalTreeClock
+type treeClock struct {
+	stamp itc.Stamp
+}
+
+func (c *treeClock) tick() {
+	c.stamp = c.stamp.Event()
+}
+
+func (c *treeClock) envelope(pid string, buf interface{}) interface{} {
+	return &ITCPayload{Pid: pid, Stamp: c.stamp.Peek(), Payload: buf}
+}
+
+func (c *treeClock) newEnvelope(unpack interface{}) interface{} {
+	return &ITCPayload{Payload: unpack}
+}
+
+func (c *treeClock) receive(envelope interface{}) bool {
+	d := envelope.(*ITCPayload)
+	if d.Pid == "" {
+		return false
+	}
+	// Only the events of the sender are joined, never its identity
+	c.stamp, _ = c.stamp.Join(d.Stamp.Peek())
+	c.stamp = c.stamp.Event()
+	return true
+}
+
+func (c *treeClock) annotate(e *Event) {
+	e.Attrs = append(append([]Attr(nil), e.Attrs...), Attr{Key: "itc", Value: c.stamp.String()})
+}
+
+// GetCurrentHLC returns the Hybrid Logical Clock timestamp of the
+// latest event of a GoLog using HybridLogicalClock, or the zero
+// timestamp for other clocks.
+func (gv *GoLog) GetCurrentHLC() hlc.Timestamp {
+	gv.mutex.RLock()
+	defer gv.mutex.RUnlock()
+	if c, ok := gv.clock.(*hybridClock); ok {
+		return c.clock.Last()
+	}
+	return hlc.Timestamp{}
+}
+
+// GetCurrentStamp returns the Interval Tree Clock stamp of a GoLog
+// using IntervalTreeClock, or an anonymous stamp for other clocks. A
+// process leaving the system hands its stamp over to another, which
+// takes over its identity with JoinStamp.
+func (gv *GoLog) GetCurrentStamp() itc.Stamp {
+	gv.mutex.RLock()
+	defer gv.mutex.RUnlock()
+	if c, ok := gv.clock.(*treeClock); ok {
+		return c.stamp
+	}
+	return itc.Stamp{}
+}
+
+// ForkStamp splits the identity of a GoLog using IntervalTreeClock
+// and returns the stamp of a new process, to be passed to it as its
+// GoLogConfig.InitialStamp. An error of kind ErrClockType is returned
+// for other clocks.
+func (gv *GoLog) ForkStamp() (itc.Stamp, error) {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	c, ok := gv.clock.(*treeClock)
+	if !ok {
+		return itc.Stamp{}, newError(ErrClockType, nil)
+	}
+	var forked itc.Stamp
+	c.stamp, forked = c.stamp.Fork()
+	return forked, nil
+}
+
+// JoinStamp takes over the identity and events of stamp, usually the
+// stamp of a process which left the system, and logs a local event
+// with mesg. An error of kind ErrClockType is returned for clocks
+// other than IntervalTreeClock, and itc.ErrOverlap if stamp shares
+// part of the identity of the GoLog.
+func (gv *GoLog) JoinStamp(mesg string, stamp itc.Stamp, opts GoLogOptions) error {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	c, ok := gv.clock.(*treeClock)
+	if !ok {
+		return newError(ErrClockType, nil)
+	}
+	joined, err := c.stamp.Join(stamp)
+	if err != nil {
+		return err
+	}
+	c.stamp = joined
+	gv.tickClock()
+	if !gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log!", LocalEvent, opts) && gv.logging {
+		return newError(ErrLogWrite, nil)
+	}
+	return nil
+}
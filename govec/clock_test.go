@@ -0,0 +1,191 @@
+package govec
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/hlc"
+	"github.com/DistributedClocks/GoVector/govec/itc"
+)
+
+func newHybridGoLog(pid string, ring *RingSink) *GoLog {
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{ring}
+	config.Clock = HybridLogicalClock
+	return InitGoVector(pid, "TestLogFile", config)
+}
+
+func TestHybridSendAndUnpack(t *testing.T) {
+
+	sendRing, recvRing := NewRingSink(8), NewRingSink(8)
+	sender := newHybridGoLog("sender", sendRing)
+	receiver := newHybridGoLog("receiver", recvRing)
+	opts := GetDefaultLogOptions()
+
+	packed, err := sender.PrepareSendE("TestSend", 1337, opts)
+	AssertTrue(t, err == nil, "HLC: PrepareSendE failed")
+	sent := sender.GetCurrentHLC()
+
+	var response int
+	err = receiver.UnpackReceiveE("TestReceive", packed, &response, opts)
+	AssertTrue(t, err == nil, "HLC: UnpackReceiveE failed")
+	AssertEquals(t, 1337, response, "HLC: payload not unpacked")
+	AssertTrue(t, sent.Before(receiver.GetCurrentHLC()), "HLC: receive not after send")
+
+	// The vector clock only counts local events
+	vc := receiver.GetCurrentVC()
+	AssertEquals(t, 1, len(vc), "HLC: remote entries merged into the vector clock")
+	n, _ := vc.FindTicks("receiver")
+	AssertEquals(t, uint64(2), n, "HLC: wrong local clock value")
+
+	// Events are logged with the hybrid clock as their timestamp
+	entries := recvRing.Entries()
+	AssertEquals(t, 2, len(entries), "HLC: expected one entry per event")
+	ts := receiver.GetCurrentHLC()
+	expected := strconv.FormatInt(ts.Wall, 10) + " receiver {\"receiver\":2}\nINFO TestReceive hlc=" + ts.String() + "\n"
+	AssertEquals(t, expected, entries[1], "HLC: wrong entry")
+}
+
+func TestHybridTimestampsFollowCausality(t *testing.T) {
+
+	rings := []*RingSink{NewRingSink(32), NewRingSink(32)}
+	gvs := []*GoLog{newHybridGoLog("a", rings[0]), newHybridGoLog("b", rings[1])}
+	opts := GetDefaultLogOptions()
+
+	// Bounce a message back and forth, every receive must be logged
+	// with a later timestamp than the matching send
+	for i := 0; i < 10; i++ {
+		from, to := gvs[i%2], gvs[(i+1)%2]
+		packed := from.PrepareSend("TestSend", i, opts)
+		sent := from.GetCurrentHLC()
+		var n int
+		to.UnpackReceive("TestReceive", packed, &n, opts)
+		AssertTrue(t, sent.Before(to.GetCurrentHLC()), "HLC: receive not after send")
+	}
+
+	for _, ring := range rings {
+		var last int64
+		for _, entry := range ring.Entries() {
+			wall, err := strconv.ParseInt(strings.SplitN(entry, " ", 2)[0], 10, 64)
+			AssertTrue(t, err == nil, "HLC: entry not timestamped")
+			AssertTrue(t, wall >= last, "HLC: timestamps went backwards")
+			last = wall
+		}
+	}
+}
+
+func TestHybridUnpackVectorClockPayload(t *testing.T) {
+
+	sender := InitGoVector("sender", "TestLogFile", GetDefaultConfig())
+	receiver := newHybridGoLog("receiver", NewRingSink(8))
+	opts := GetDefaultLogOptions()
+
+	packed := sender.PrepareSend("TestSend", 1337, opts)
+	var response int
+	err := receiver.UnpackReceiveE("TestReceive", packed, &response, opts)
+	AssertTrue(t, errors.Is(err, ErrDecode), "HLC: vector clock payload decoded")
+}
+
+func newTreeGoLog(pid string, ring *RingSink, stamp itc.Stamp) *GoLog {
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{ring}
+	config.Clock = IntervalTreeClock
+	config.InitialStamp = stamp
+	return InitGoVector(pid, "TestLogFile", config)
+}
+
+// treeRecords parses the stamps logged by GoLogs using
+// IntervalTreeClock
+func treeRecords(t *testing.T, pid string, ring *RingSink) []itc.Record {
+	var records []itc.Record
+	for _, entry := range ring.Entries() {
+		i := strings.Index(entry, " itc=")
+		AssertTrue(t, i >= 0, "ITC: entry not stamped")
+		// Stamps hold spaces, they are logged quoted
+		quoted, err := strconv.Unquote(strings.TrimSuffix(entry[i+len(" itc="):], "\n"))
+		AssertTrue(t, err == nil, "ITC: logged stamp not quoted")
+		stamp, err := itc.Parse(quoted)
+		AssertTrue(t, err == nil, "ITC: logged stamp does not parse")
+		records = append(records, itc.Record{Pid: pid, Stamp: stamp})
+	}
+	return records
+}
+
+func TestTreeSendAndUnpack(t *testing.T) {
+
+	ringA, ringB := NewRingSink(8), NewRingSink(8)
+	a := newTreeGoLog("a", ringA, itc.Seed())
+	forked, err := a.ForkStamp()
+	AssertTrue(t, err == nil, "ITC: ForkStamp failed")
+	b := newTreeGoLog("b", ringB, forked)
+	opts := GetDefaultLogOptions()
+
+	packed, err := a.PrepareSendE("TestSend", 1337, opts)
+	AssertTrue(t, err == nil, "ITC: PrepareSendE failed")
+	var response int
+	err = b.UnpackReceiveE("TestReceive", packed, &response, opts)
+	AssertTrue(t, err == nil, "ITC: UnpackReceiveE failed")
+	AssertEquals(t, 1337, response, "ITC: payload not unpacked")
+	AssertTrue(t, a.GetCurrentStamp().Leq(b.GetCurrentStamp()), "ITC: receive not after send")
+
+	// b leaves, a takes its identity over
+	AssertTrue(t, a.JoinStamp("TestJoin", b.GetCurrentStamp(), opts) == nil, "ITC: JoinStamp failed")
+	AssertTrue(t, b.GetCurrentStamp().Leq(a.GetCurrentStamp()), "ITC: join not after leave")
+
+	// The logged stamps convert into vector clocks ShiViz can render
+	records := append(treeRecords(t, "a", ringA), treeRecords(t, "b", ringB)...)
+	AssertEquals(t, 5, len(records), "ITC: expected one entry per event")
+	vcs := itc.VClocks(records)
+	AssertEquals(t, `{"a":2}`, vcs[1].ReturnVCString(), "ITC: wrong send clock")
+	AssertEquals(t, `{"a":2, "b":2}`, vcs[4].ReturnVCString(), "ITC: wrong receive clock")
+	AssertEquals(t, `{"a":3, "b":2}`, vcs[2].ReturnVCString(), "ITC: wrong join clock")
+}
+
+func TestTreeMissingStamp(t *testing.T) {
+
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Clock = IntervalTreeClock
+	gv, err := InitGoVectorE(TestPID, "TestLogFile", config)
+	AssertTrue(t, errors.Is(err, ErrConfig), "ITC: expected ErrConfig without an InitialStamp")
+	AssertTrue(t, gv == nil, "ITC: GoLog returned along with an error")
+}
+
+func TestForkStampClockType(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	_, err := gv.ForkStamp()
+	AssertTrue(t, errors.Is(err, ErrClockType), "ForkStamp: expected ErrClockType")
+}
+
+func TestHybridBroadcast(t *testing.T) {
+
+	sender := newHybridGoLog("sender", NewRingSink(8))
+	opts := GetDefaultLogOptions()
+
+	var packed [][]byte
+	sender.Broadcast("TestBroadcast", opts, func(send BroadcastSender) error {
+		sent := sender.GetCurrentHLC()
+		// Later events must not change the clock of the broadcast
+		sender.LogLocalEvent("TestLocal", opts)
+		for i := 0; i < 2; i++ {
+			buf, err := send.PrepareSend(i)
+			AssertTrue(t, err == nil, "HLC: BroadcastSender failed")
+			packed = append(packed, buf)
+		}
+		for i, buf := range packed {
+			var d HLCPayload
+			var response int
+			d.Payload = &response
+			AssertTrue(t, sender.decodingStrategy(buf, &d) == nil, "HLC: broadcast not decoded")
+			AssertEquals(t, sent, hlc.Timestamp{Wall: d.Wall, Logical: d.Logical}, "HLC: wrong broadcast clock")
+			AssertEquals(t, i, response, "HLC: wrong broadcast payload")
+		}
+		return nil
+	})
+	AssertEquals(t, 2, len(packed), "HLC: broadcast not sent")
+}
@@ -0,0 +1,51 @@
+package govec
+
+import (
+	"context"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// clockContextKey is the key under which a vector clock is stored in
+// a context.Context
+type clockContextKey struct{}
+
+// ContextWithClock returns a copy of ctx carrying a copy of vc. The
+// clock can be retrieved with ClockFromContext, and is merged into the
+// local clock of a GoLog by PrepareSendCtx.
+func ContextWithClock(ctx context.Context, vc vclock.VClock) context.Context {
+	return context.WithValue(ctx, clockContextKey{}, vc.Copy())
+}
+
+// ClockFromContext returns a copy of the vector clock carried by ctx,
+// if any.
+func ClockFromContext(ctx context.Context) (vclock.VClock, bool) {
+	vc, ok := ctx.Value(clockContextKey{}).(vclock.VClock)
+	if !ok {
+		return nil, false
+	}
+	return vc.Copy(), true
+}
+
+// PrepareSendCtx behaves like PrepareSendE, but first merges the
+// vector clock carried by ctx, if any, into the local clock. This lets
+// a message sent on behalf of a request causally follow the events
+// which produced that request, even when they were logged by another
+// GoLog.
+func (gv *GoLog) PrepareSendCtx(ctx context.Context, mesg string, buf interface{}, opts GoLogOptions) ([]byte, error) {
+	incoming, _ := ClockFromContext(ctx)
+	return gv.prepareSend(incoming, "", mesg, buf, opts)
+}
+
+// UnpackReceiveCtx behaves like UnpackReceiveE and returns a copy of
+// ctx carrying the local vector clock right after the receive event.
+// Passing the returned context down the call chain lets later calls to
+// PrepareSendCtx pick the clock up. If nothing was merged, because of
+// an error or the priority of the event, ctx is returned unchanged.
+func (gv *GoLog) UnpackReceiveCtx(ctx context.Context, mesg string, buf []byte, unpack interface{}, opts GoLogOptions) (context.Context, error) {
+	vc, err := gv.unpackReceive(mesg, buf, unpack, opts)
+	if vc != nil {
+		ctx = context.WithValue(ctx, clockContextKey{}, vc)
+	}
+	return ctx, err
+}
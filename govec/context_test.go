@@ -0,0 +1,79 @@
+package govec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestClockFromContext(t *testing.T) {
+
+	_, found := ClockFromContext(context.Background())
+	AssertTrue(t, !found, "Context: clock found in empty context")
+
+	vc := vclock.VClock{TestPID: 3}
+	ctx := ContextWithClock(context.Background(), vc)
+	vc.Tick(TestPID)
+
+	fromCtx, found := ClockFromContext(ctx)
+	AssertTrue(t, found, "Context: clock not found")
+	AssertEquals(t, uint64(3), fromCtx[TestPID], "Context: clock not copied")
+}
+
+func TestContextPropagation(t *testing.T) {
+
+	sender := InitGoVector("Sender", "TestLogFile", GetDefaultConfig())
+	frontend := InitGoVector("Frontend", "TestLogFile", GetDefaultConfig())
+	backend := InitGoVector("Backend", "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	packed := sender.PrepareSend("TestMessage1", 1337, opts)
+
+	var response int
+	ctx, err := frontend.UnpackReceiveCtx(context.Background(), "TestMessage2", packed, &response, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vc, found := ClockFromContext(ctx)
+	AssertTrue(t, found, "UnpackReceiveCtx: clock not stored in context")
+	AssertEquals(t, uint64(2), vc["Sender"], "UnpackReceiveCtx: wrong clock in context")
+	AssertEquals(t, uint64(2), vc["Frontend"], "UnpackReceiveCtx: wrong clock in context")
+
+	if _, err := backend.PrepareSendCtx(ctx, "TestMessage3", 1337, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	vc = backend.GetCurrentVC()
+	AssertEquals(t, uint64(2), vc["Sender"], "PrepareSendCtx: context clock not merged")
+	AssertEquals(t, uint64(2), vc["Frontend"], "PrepareSendCtx: context clock not merged")
+	AssertEquals(t, uint64(2), vc["Backend"], "PrepareSendCtx: Clock value not incremented")
+}
+
+func TestUnpackReceiveCtxError(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	var response int
+	ctx, err := gv.UnpackReceiveCtx(context.Background(), "TestMessage1", []byte{0xc1}, &response, opts)
+
+	AssertTrue(t, err != nil, "UnpackReceiveCtx: expected an error")
+	_, found := ClockFromContext(ctx)
+	AssertTrue(t, !found, "UnpackReceiveCtx: clock stored on failure")
+}
+
+func TestPrepareSendCtxError(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	ctx := ContextWithClock(context.Background(), vclock.VClock{"q": 9})
+	packed, err := gv.PrepareSendCtx(ctx, "TestMessage1", make(chan int), opts)
+
+	AssertTrue(t, errors.Is(err, ErrEncode), "PrepareSendCtx: expected an encode error")
+	AssertTrue(t, packed == nil, "PrepareSendCtx: expected no encoded bytes")
+	AssertEquals(t, `{"TestPID":1}`, gv.GetCurrentVC().ReturnVCString(), "PrepareSendCtx: Clock not restored on failure")
+}
@@ -0,0 +1,62 @@
+package govec
+
+import "github.com/DistributedClocks/GoVector/govec/vclock"
+
+// peerState records what a peer knows of the local vector clock
+type peerState struct {
+	// sent holds the entries sent to the peer so far
+	sent vclock.VClock
+	// deltas counts the delta encoded messages sent since the last
+	// full clock
+	deltas int
+}
+
+// PrepareSendTo behaves like PrepareSendE for a message sent to peer,
+// the id of the receiving process or of the channel leading to it.
+// When GoLogConfig.DeltaEncoding is set, the message only carries the
+// entries of the vector clock which changed since the previous message
+// sent to peer, which is enough for a receiver getting every message
+// in order. Receivers unpack such messages with UnpackReceive as
+// usual. If messages to peer may have been lost or reordered, for
+// instance after a reconnection, call ResyncPeer so that the next
+// message carries the full clock again.
+func (gv *GoLog) PrepareSendTo(peer string, mesg string, buf interface{}, opts GoLogOptions) ([]byte, error) {
+	return gv.prepareSend(nil, peer, mesg, buf, opts)
+}
+
+// ResyncPeer makes the next message sent to peer with PrepareSendTo
+// carry the full vector clock.
+func (gv *GoLog) ResyncPeer(peer string) {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	delete(gv.peers, peer)
+}
+
+// deltaClock returns the entries of the local clock peer does not know
+// of, and whether the whole clock is returned to resynchronize peer.
+func (gv *GoLog) deltaClock(peer string) (vclock.VClock, bool) {
+	p := gv.peers[peer]
+	if p == nil || (gv.deltaResync > 0 && p.deltas >= gv.deltaResync) {
+		return gv.currentVC, true
+	}
+	delta := vclock.New()
+	for id, ticks := range gv.currentVC {
+		if sent, found := p.sent[id]; !found || ticks > sent {
+			delta[id] = ticks
+		}
+	}
+	return delta, false
+}
+
+// sentTo records that the entries of sent were sent to peer.
+func (gv *GoLog) sentTo(peer string, sent vclock.VClock, full bool) {
+	if full {
+		gv.peers[peer] = &peerState{sent: sent.Copy()}
+		return
+	}
+	p := gv.peers[peer]
+	for id, ticks := range sent {
+		p.sent[id] = ticks
+	}
+	p.deltas++
+}
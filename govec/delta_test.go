@@ -0,0 +1,100 @@
+package govec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// sentClock decodes the clock carried by a message
+func sentClock(t testing.TB, gv *GoLog, packed []byte) vclock.VClock {
+	var d VClockPayload
+	if err := gv.decodingStrategy(packed, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d.VcMap
+}
+
+func TestDeltaEncoding(t *testing.T) {
+
+	config := GetDefaultConfig()
+	config.DeltaEncoding = true
+	config.InitialVC = vclock.VClock{"sender": 0, "a": 3, "b": 5}
+	sender := InitGoVector("sender", "TestLogFile", config)
+	receiver := InitGoVector("receiver", "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+	var response int
+
+	// The first message carries the full clock
+	packed, err := sender.PrepareSendTo("receiver", "TestMessage1", 1, opts)
+	AssertTrue(t, err == nil, "PrepareSendTo failed")
+	AssertEquals(t, `{"a":3, "b":5, "sender":2}`, sentClock(t, sender, packed).ReturnVCString(), "Delta: first message not full")
+	receiver.UnpackReceive("TestMessage1", packed, &response, opts)
+
+	// Later ones only the changed entries
+	sender.LogLocalEvent("TestMessage2", opts)
+	packed, _ = sender.PrepareSendTo("receiver", "TestMessage3", 2, opts)
+	AssertEquals(t, `{"sender":4}`, sentClock(t, sender, packed).ReturnVCString(), "Delta: unchanged entries sent")
+	receiver.UnpackReceive("TestMessage3", packed, &response, opts)
+	AssertEquals(t, `{"a":3, "b":5, "receiver":3, "sender":4}`, receiver.GetCurrentVC().ReturnVCString(), "Delta: receiver clock not up to date")
+
+	// Other peers get the full clock
+	packed, _ = sender.PrepareSendTo("other", "TestMessage4", 3, opts)
+	AssertEquals(t, 3, len(sentClock(t, sender, packed)), "Delta: first message to another peer not full")
+
+	sender.ResyncPeer("receiver")
+	packed, _ = sender.PrepareSendTo("receiver", "TestMessage5", 4, opts)
+	AssertEquals(t, 3, len(sentClock(t, sender, packed)), "Delta: message after ResyncPeer not full")
+}
+
+func TestDeltaResync(t *testing.T) {
+
+	config := GetDefaultConfig()
+	config.DeltaEncoding = true
+	config.DeltaResync = 1
+	config.InitialVC = vclock.VClock{"sender": 0, "a": 3}
+	sender := InitGoVector("sender", "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	for i, expected := range []int{2, 1, 2, 1} {
+		packed, _ := sender.PrepareSendTo("receiver", "TestMessage", i, opts)
+		AssertEquals(t, expected, len(sentClock(t, sender, packed)), "DeltaResync: wrong number of entries")
+	}
+}
+
+func BenchmarkDeltaWireSize(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		initial := vclock.New()
+		ids := make([]string, size)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("process%d", i)
+			initial.Set(ids[i], uint64(i))
+		}
+
+		for _, delta := range []bool{false, true} {
+			name := fmt.Sprintf("full/%d", size)
+			if delta {
+				name = fmt.Sprintf("delta/%d", size)
+			}
+			b.Run(name, func(b *testing.B) {
+				config := GetDefaultConfig()
+				config.LogToFile = false
+				config.DeltaEncoding = delta
+				config.InitialVC = initial
+				gv := InitGoVector(ids[0], "TestLogFile", config)
+				opts := GetDefaultLogOptions()
+
+				total := 0
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// Consecutive messages differ in a couple of entries
+					gv.currentVC.Tick(ids[i%size])
+					packed, _ := gv.PrepareSendTo("peer", "Sending", i, opts)
+					total += len(packed)
+				}
+				b.ReportMetric(float64(total)/float64(b.N), "bytes/msg")
+			})
+		}
+	}
+}
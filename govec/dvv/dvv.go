@@ -0,0 +1,146 @@
+// Package dvv implements dotted version vectors, which version the
+// values of a replicated key/value store. Versioning values with plain
+// vector clocks holding one entry per server makes two writes handled
+// by the same server look causally ordered, even when their clients
+// wrote concurrently, and one of them is silently lost. A dotted
+// version vector tells the write itself, its dot, apart from the causal
+// context the client read before writing, so that such writes are kept
+// as concurrent siblings.
+//
+// Versions holds the siblings of a key as individually versioned
+// values, Set is the compact encoding known as DVV sets, which holds a
+// single clock for all the siblings of a key. Both use vclock.VClock
+// for causal contexts, which clients read along with the values and
+// send back with their writes.
+//
+// See Preguiça, Baquero, Almeida, Fonte and Gonçalves, "Dotted
+// Version Vectors: Logical Clocks for Optimistic Replication" (2010).
+package dvv
+
+import (
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// Dot identifies a single write: the Counter-th write handled by
+// server Node.
+type Dot struct {
+	Node    string
+	Counter uint64
+}
+
+// DVV is a dotted version vector: the dot of a write and the causal
+// context of the value it replaced.
+type DVV struct {
+	Dot     Dot
+	Context vclock.VClock
+}
+
+// Contains reports whether the write with dot d happened before or is
+// the write versioned by v.
+func (v DVV) Contains(d Dot) bool {
+	return v.Dot == d || d.Counter <= v.Context[d.Node]
+}
+
+// Descends reports whether the write versioned by v happened after or
+// is the write versioned by w.
+func (v DVV) Descends(w DVV) bool {
+	return v.Contains(w.Dot)
+}
+
+// ConcurrentWith reports whether neither of v and w descends from the
+// other, in which case both values must be kept as siblings.
+func (v DVV) ConcurrentWith(w DVV) bool {
+	return !v.Descends(w) && !w.Descends(v)
+}
+
+// History returns the vector clock of the writes known to v, that is
+// its context with its dot added, which loses the information that
+// the write itself may not follow the other writes of its server.
+func (v DVV) History() vclock.VClock {
+	h := v.Context.Copy()
+	if h[v.Dot.Node] < v.Dot.Counter {
+		h[v.Dot.Node] = v.Dot.Counter
+	}
+	return h
+}
+
+// Version is a value along with its dotted version vector.
+type Version struct {
+	Clock DVV
+	Value interface{}
+}
+
+// Versions are the sibling values of a key, none of which descends
+// from another. The zero Versions holds no value.
+type Versions []Version
+
+// Join returns the causal context covering every sibling, to be read
+// by clients along with the values and sent back with their next
+// write.
+func (vs Versions) Join() vclock.VClock {
+	ctx := vclock.New()
+	for _, v := range vs {
+		ctx.Merge(v.Clock.History())
+	}
+	return ctx
+}
+
+// Values returns the values of the siblings.
+func (vs Versions) Values() []interface{} {
+	values := make([]interface{}, len(vs))
+	for i, v := range vs {
+		values[i] = v.Value
+	}
+	return values
+}
+
+// Conflict reports whether there are concurrent siblings, which the
+// application must reconcile.
+func (vs Versions) Conflict() bool {
+	return len(vs) > 1
+}
+
+// Update returns the siblings after server node handled the write of
+// value by a client which read ctx. The siblings ctx covers are
+// replaced, the others are kept as concurrent with the new value.
+func (vs Versions) Update(ctx vclock.VClock, node string, value interface{}) Versions {
+	// The new dot follows every write of node known to the server
+	counter := vs.Join()[node] + 1
+	if counter <= ctx[node] {
+		counter = ctx[node] + 1
+	}
+	write := Version{Clock: DVV{Dot: Dot{Node: node, Counter: counter}, Context: ctx.Copy()}, Value: value}
+
+	updated := Versions{}
+	for _, v := range vs {
+		if !write.Clock.Descends(v.Clock) {
+			updated = append(updated, v)
+		}
+	}
+	return append(updated, write)
+}
+
+// Sync returns the siblings of two replicas of the same key, dropping
+// those which another sibling descends from.
+func (vs Versions) Sync(other Versions) Versions {
+	synced := Versions{}
+	all := append(append(Versions{}, vs...), other...)
+	for i, v := range all {
+		obsolete := false
+		for j, w := range all {
+			if i == j {
+				continue
+			}
+			// Drop v if it is strictly older than w or a duplicate
+			// of a later sibling
+			if w.Clock.Descends(v.Clock) && (w.Clock.Dot != v.Clock.Dot || j > i) {
+				obsolete = true
+				break
+			}
+		}
+		if !obsolete {
+			synced = append(synced, v)
+		}
+	}
+	return synced
+}
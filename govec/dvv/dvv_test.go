@@ -0,0 +1,122 @@
+package dvv
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// sortedValues formats values in a canonical order
+func sortedValues(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	sort.Strings(s)
+	return fmt.Sprint(s)
+}
+
+func TestConcurrentWritesSameServer(t *testing.T) {
+
+	// Two clients read the empty key and write through the same server
+	var vs Versions
+	var set Set
+	empty := vclock.New()
+	vs = vs.Update(empty, "server", "v1")
+	vs = vs.Update(empty, "server", "v2")
+	set.Update(empty, "server", "v1")
+	set.Update(empty, "server", "v2")
+
+	// Per server vector clocks order the writes and lose v1
+	AssertTrue(t, vclock.HappenedBefore(vclock.VClock{"server": 1}, vclock.VClock{"server": 2}), "VClock: writes should look ordered")
+
+	// Dotted version vectors keep both
+	AssertTrue(t, vs[0].Clock.ConcurrentWith(vs[1].Clock), "DVV: writes should be concurrent")
+	AssertTrue(t, vs.Conflict(), "Versions: conflict not detected")
+	AssertTrue(t, set.Conflict(), "Set: conflict not detected")
+	AssertEquals(t, "[v1 v2]", sortedValues(vs.Values()), "Versions: wrong siblings")
+	AssertEquals(t, "[v1 v2]", sortedValues(set.Values()), "Set: wrong siblings")
+
+	// A client which read both siblings reconciles them
+	AssertEquals(t, `{"server":2}`, vs.Join().ReturnVCString(), "Versions: wrong context")
+	AssertEquals(t, `{"server":2}`, set.Join().ReturnVCString(), "Set: wrong context")
+	vs = vs.Update(vs.Join(), "server", "v3")
+	set.Update(set.Join(), "server", "v3")
+	AssertTrue(t, !vs.Conflict(), "Versions: siblings not replaced")
+	AssertEquals(t, "[v3]", sortedValues(set.Values()), "Set: siblings not replaced")
+}
+
+func TestSync(t *testing.T) {
+
+	var a, b Set
+	a.Update(vclock.New(), "a", "v1")
+	b.Sync(&a)
+	// b replaces v1 while a gets a concurrent write
+	b.Update(b.Join(), "b", "v2")
+	a.Update(vclock.New(), "a", "v3")
+
+	a.Sync(&b)
+	b.Sync(&a)
+	AssertEquals(t, "[v2 v3]", sortedValues(a.Values()), "Sync: wrong siblings")
+	AssertEquals(t, a.Join().ReturnVCString(), b.Join().ReturnVCString(), "Sync: replicas differ")
+	AssertTrue(t, a.Descends(&b) && b.Descends(&a), "Sync: replicas should descend from each other")
+}
+
+func TestDVVDescends(t *testing.T) {
+
+	v1 := DVV{Dot: Dot{"a", 1}, Context: vclock.New()}
+	v2 := DVV{Dot: Dot{"b", 1}, Context: vclock.VClock{"a": 1}}
+	AssertTrue(t, v2.Descends(v1) && !v1.Descends(v2), "Descends: wrong order")
+	AssertTrue(t, v1.Descends(v1), "Descends: not reflexive")
+	AssertEquals(t, `{"a":1, "b":1}`, v2.History().ReturnVCString(), "History: wrong clock")
+}
+
+// TestVersionsMatchSet runs random writes and syncs over replicas
+// holding both representations, which must agree.
+func TestVersionsMatchSet(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	nodes := []string{"a", "b", "c"}
+	for run := 0; run < 200; run++ {
+		versions := make([]Versions, len(nodes))
+		sets := make([]*Set, len(nodes))
+		for i := range sets {
+			sets[i] = &Set{}
+		}
+
+		for step := 0; step < 30; step++ {
+			i, j := r.Intn(len(nodes)), r.Intn(len(nodes))
+			if r.Intn(2) == 0 {
+				// A client reads replica j, possibly stale, and
+				// writes through replica i
+				ctx := versions[j].Join()
+				AssertEquals(t, ctx.ReturnVCString(), sets[j].Join().ReturnVCString(), "Join: representations differ")
+				if r.Intn(4) == 0 {
+					ctx = vclock.New()
+				}
+				value := fmt.Sprintf("%d.%d", run, step)
+				versions[i] = versions[i].Update(ctx, nodes[i], value)
+				sets[i].Update(ctx, nodes[i], value)
+			} else {
+				versions[i] = versions[i].Sync(versions[j])
+				sets[i].Sync(sets[j])
+			}
+			AssertEquals(t, sortedValues(versions[i].Values()), sortedValues(sets[i].Values()), "Values: representations differ")
+		}
+	}
+}
+
+func AssertTrue(t *testing.T, condition bool, message string) {
+	if !condition {
+		t.Fatalf(message)
+	}
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+" Expected: %v, Actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,125 @@
+package dvv
+
+import (
+	"sort"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// entry holds, for one server, the number of writes it handled and
+// the values of the latest ones still alive, newest first: the value
+// at index k was written with dot (node, counter-k).
+type entry struct {
+	node    string
+	counter uint64
+	values  []interface{}
+}
+
+// Set is a DVV set: the siblings of a key along with a single clock
+// with one entry per server, the compact form of Versions. The zero
+// Set holds no value. A Set is not safe for concurrent use.
+//
+// See Gonçalves, Almeida, Baquero and Fonte, "Dotted Version Vector
+// Sets" (2014).
+type Set struct {
+	// entries are sorted by node
+	entries []entry
+}
+
+func (s *Set) find(node string) int {
+	return sort.Search(len(s.entries), func(i int) bool { return s.entries[i].node >= node })
+}
+
+// entryFor returns the entry of node, adding it if needed
+func (s *Set) entryFor(node string) *entry {
+	i := s.find(node)
+	if i == len(s.entries) || s.entries[i].node != node {
+		s.entries = append(s.entries, entry{})
+		copy(s.entries[i+1:], s.entries[i:])
+		s.entries[i] = entry{node: node}
+	}
+	return &s.entries[i]
+}
+
+// Join returns the causal context covering every sibling, to be read
+// by clients along with the values and sent back with their next
+// write.
+func (s *Set) Join() vclock.VClock {
+	ctx := vclock.New()
+	for _, e := range s.entries {
+		ctx.Set(e.node, e.counter)
+	}
+	return ctx
+}
+
+// Values returns the values of the siblings.
+func (s *Set) Values() []interface{} {
+	var values []interface{}
+	for _, e := range s.entries {
+		values = append(values, e.values...)
+	}
+	return values
+}
+
+// Conflict reports whether there are concurrent siblings, which the
+// application must reconcile.
+func (s *Set) Conflict() bool {
+	return len(s.Values()) > 1
+}
+
+// Update records that server node handled the write of value by a
+// client which read ctx. The siblings ctx covers are replaced, the
+// others are kept as concurrent with the new value.
+func (s *Set) Update(ctx vclock.VClock, node string, value interface{}) {
+	// Discard the values the client has seen
+	for i := range s.entries {
+		e := &s.entries[i]
+		seen := ctx[e.node]
+		if seen >= e.counter {
+			e.values = nil
+		} else if alive := e.counter - seen; alive < uint64(len(e.values)) {
+			e.values = e.values[:alive]
+		}
+	}
+	// The context of the client becomes known
+	for id, counter := range ctx {
+		if e := s.entryFor(id); e.counter < counter {
+			e.counter = counter
+		}
+	}
+
+	e := s.entryFor(node)
+	e.counter++
+	e.values = append([]interface{}{value}, e.values...)
+}
+
+// Sync merges the siblings of other, another replica of the same key,
+// dropping the values which one replica knows were replaced.
+func (s *Set) Sync(other *Set) {
+	for _, o := range other.entries {
+		e := s.entryFor(o.node)
+		e.counter, e.values = syncEntry(e.counter, e.values, o.counter, o.values)
+	}
+	// The values of servers other knows nothing of are kept as is
+}
+
+// syncEntry merges two entries of the same server. A value is alive
+// in an entry if it is listed, or was written after the entry was last
+// updated, so a value survives if it is alive in both.
+func syncEntry(n1 uint64, l1 []interface{}, n2 uint64, l2 []interface{}) (uint64, []interface{}) {
+	if n1 < n2 {
+		n1, l1, n2, l2 = n2, l2, n1, l1
+	}
+	// The values of l1 are those written after n1-len(l1), and those
+	// of l2 after n2-len(l2)
+	floor2 := n2 - uint64(len(l2))
+	if n1-uint64(len(l1)) >= floor2 {
+		return n1, l1
+	}
+	return n1, append([]interface{}(nil), l1[:n1-floor2]...)
+}
+
+// Descends reports whether s knows of every write other knows of.
+func (s *Set) Descends(other *Set) bool {
+	return vclock.Relation(other.Join(), s.Join())&(vclock.Ancestor|vclock.Equal) != 0
+}
@@ -0,0 +1,106 @@
+package govec
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error kinds reported by the error returning variants of the GoLog
+// API. They can be matched against any error returned by GoLog with
+// errors.Is.
+var (
+	// ErrEncode is reported when a message could not be encoded with
+	// the configured EncodingStrategy.
+	ErrEncode = errors.New("govec: could not encode message")
+	// ErrDecode is reported when a received message could not be
+	// decoded with the configured DecodingStrategy.
+	ErrDecode = errors.New("govec: could not decode message")
+	// ErrMissingPid is reported when a received clock does not name
+	// the process that sent it.
+	ErrMissingPid = errors.New("govec: message is missing the sender's process id")
+	// ErrLogWrite is reported when an event could not be written to
+	// the log.
+	ErrLogWrite = errors.New("govec: could not write to log")
+	// ErrClockType is reported when an operation is not supported by
+	// the clock selected with GoLogConfig.Clock.
+	ErrClockType = errors.New("govec: operation not supported by the clock in use")
+	// ErrBroadcastDone is reported when a BroadcastSender is used
+	// after the broadcast it belongs to is over.
+	ErrBroadcastDone = errors.New("govec: broadcast is over")
+	// ErrClockSave is reported when the clock could not be saved to
+	// the ClockStore.
+	ErrClockSave = errors.New("govec: could not save the clock")
+	// ErrConfig is reported when a GoLogConfig is invalid.
+	ErrConfig = errors.New("govec: invalid configuration")
+)
+
+// Error is the error type returned by the error returning variants of
+// the GoLog API. Kind is one of the ErrXxx values declared by this
+// package and Err, if set, is the underlying cause.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func newError(kind error, err error) *Error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of this error.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// multiError gathers the errors of an operation which failed in
+// several ways
+type multiError []error
+
+// joinErrors returns the errors of errs which are not nil gathered in
+// a multiError, the error itself if there is one, nil if there is none.
+func joinErrors(errs ...error) error {
+	var m multiError
+	for _, err := range errs {
+		if err != nil {
+			m = append(m, err)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+	return m
+}
+
+// Error returns the messages of the errors separated by semicolons.
+func (m multiError) Error() string {
+	messages := make([]string, len(m))
+	for i, err := range m {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package govec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// EventKind identifies the action that produced a logged Event.
+type EventKind int
+
+// EventKind enum provides the kinds of events GoLog produces.
+const (
+	// LocalEvent is produced by LogLocalEvent
+	LocalEvent EventKind = iota
+	// SendEvent is produced when a message is prepared for sending
+	SendEvent
+	// ReceiveEvent is produced when a received message is unpacked
+	ReceiveEvent
+	// SystemEvent is produced by GoVector itself, such as the
+	// initialization of a GoLog or the start of a new execution in
+	// an appended log
+	SystemEvent
+)
+
+// kindLookup translates event kinds into strings
+var kindLookup = [...]string{
+	LocalEvent:   "local",
+	SendEvent:    "send",
+	ReceiveEvent: "receive",
+	SystemEvent:  "system",
+}
+
+// String returns the name of the event kind.
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(kindLookup) {
+		return "EventKind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return kindLookup[k]
+}
+
+// Attr is a key/value pair attached to a logged Event.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// Event is a single logging event. Every event logged by GoLog is
+// built as an Event and turned into a log entry by a Formatter.
+type Event struct {
+	// Pid is the id of the logging process, empty for events which
+	// do not belong to a process such as execution banners
+	Pid string
+	// VC is a copy of the vector clock at the time of the event, nil
+	// for events which do not carry a clock
+	VC vclock.VClock
+	// Priority is the priority the event was logged with
+	Priority LogPriority
+	// Time is the wall time at which the event was logged
+	Time time.Time
+	// Message is the message the event was logged with
+	Message string
+	// Attrs are additional key/value pairs attached to the event
+	Attrs []Attr
+	// Kind is the action which produced the event
+	Kind EventKind
+}
+
+// Formatter turns an Event into a log entry. The returned entry must
+// be complete, including its trailing newline.
+type Formatter interface {
+	Format(e *Event) ([]byte, error)
+}
+
+// ShiVizFormatter formats events into the ShiViz log format, which
+// GoVector has always produced: a line holding the process id and
+// the vector clock, followed by a line holding the event. If
+// UseTimestamps is set the first line is prefixed by the event's time
+// in nanoseconds, as expected by TSViz.
+type ShiVizFormatter struct {
+	UseTimestamps bool
+}
+
+// Format formats e into the ShiViz log format.
+func (f ShiVizFormatter) Format(e *Event) ([]byte, error) {
+	var buffer bytes.Buffer
+	if f.UseTimestamps {
+		buffer.WriteString(strconv.FormatInt(e.Time.UnixNano(), 10))
+		buffer.WriteString(" ")
+	}
+	buffer.WriteString(e.Pid)
+	buffer.WriteString(" ")
+	if e.VC != nil {
+		buffer.WriteString(e.VC.ReturnVCString())
+	}
+	buffer.WriteString("\n")
+	if e.Kind != SystemEvent {
+		buffer.WriteString(prefixLookup[e.Priority])
+		buffer.WriteString(" ")
+	}
+	buffer.WriteString(e.Message)
+	for _, attr := range e.Attrs {
+		fmt.Fprintf(&buffer, " %s=%s", attr.Key, formatAttrValue(attr.Value))
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
+
+// formatAttrValue formats the value of an attribute, quoted if it
+// holds a space, an equal sign, a quote or a control character, which
+// would make the entry ambiguous or split it over several lines.
+func formatAttrValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	for _, r := range s {
+		if r == ' ' || r == '=' || r == '"' || unicode.IsControl(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// JSONFormatter formats every event as a JSON object on its own line.
+type JSONFormatter struct{}
+
+// jsonEvent is the JSON representation of an Event
+type jsonEvent struct {
+	Pid      string                 `json:"pid,omitempty"`
+	VC       map[string]uint64      `json:"clock,omitempty"`
+	Priority string                 `json:"priority"`
+	Time     time.Time              `json:"time"`
+	Message  string                 `json:"message"`
+	Attrs    map[string]interface{} `json:"attrs,omitempty"`
+	Kind     string                 `json:"kind"`
+}
+
+// Format formats e as a line of JSON.
+func (f JSONFormatter) Format(e *Event) ([]byte, error) {
+	je := jsonEvent{
+		Pid:      e.Pid,
+		VC:       e.VC.GetMap(),
+		Priority: prefixLookup[e.Priority],
+		Time:     e.Time,
+		Message:  e.Message,
+		Kind:     e.Kind.String(),
+	}
+	if len(e.Attrs) > 0 {
+		je.Attrs = make(map[string]interface{}, len(e.Attrs))
+		for _, attr := range e.Attrs {
+			je.Attrs[attr.Key] = attr.Value
+		}
+	}
+	entry, err := json.Marshal(&je)
+	if err != nil {
+		return nil, err
+	}
+	return append(entry, '\n'), nil
+}
@@ -0,0 +1,102 @@
+package govec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// recordingFormatter keeps every event it formats
+type recordingFormatter struct {
+	events []*Event
+}
+
+func (f *recordingFormatter) Format(e *Event) ([]byte, error) {
+	f.events = append(f.events, e)
+	return ShiVizFormatter{}.Format(e)
+}
+
+func TestShiVizFormatter(t *testing.T) {
+
+	e := &Event{
+		Pid:      TestPID,
+		VC:       vclock.VClock{TestPID: 3, "Other": 1},
+		Priority: WARNING,
+		Time:     time.Unix(0, 42),
+		Message:  "TestMessage1",
+		Attrs:    []Attr{{Key: "key", Value: 7}},
+		Kind:     SendEvent,
+	}
+
+	entry, _ := ShiVizFormatter{}.Format(e)
+	AssertEquals(t, "TestPID {\"Other\":1, \"TestPID\":3}\nWARNING TestMessage1 key=7\n", string(entry), "ShiVizFormatter: wrong entry")
+
+	entry, _ = ShiVizFormatter{UseTimestamps: true}.Format(e)
+	AssertEquals(t, "42 TestPID {\"Other\":1, \"TestPID\":3}\nWARNING TestMessage1 key=7\n", string(entry), "ShiVizFormatter: wrong entry")
+}
+
+func TestShiVizFormatterQuotesAttrs(t *testing.T) {
+
+	e := &Event{
+		Pid:      TestPID,
+		VC:       vclock.VClock{TestPID: 1},
+		Priority: INFO,
+		Message:  "TestMessage1",
+		Attrs:    []Attr{{Key: "stack", Value: "line 1\nline 2"}, {Key: "user", Value: "a b"}, {Key: "expr", Value: "x=1"}, {Key: "plain", Value: "ok"}},
+		Kind:     LocalEvent,
+	}
+
+	entry, _ := ShiVizFormatter{}.Format(e)
+	AssertEquals(t, "TestPID {\"TestPID\":1}\nINFO TestMessage1 stack=\"line 1\\nline 2\" user=\"a b\" expr=\"x=1\" plain=ok\n", string(entry), "ShiVizFormatter: attribute values not quoted")
+	AssertEquals(t, 2, strings.Count(string(entry), "\n"), "ShiVizFormatter: entry split over several lines")
+}
+
+func TestJSONFormatter(t *testing.T) {
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{ring}
+	config.Formatter = JSONFormatter{}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	gv.LogLocalEvent("TestMessage1", opts.SetAttr("key", "value"))
+
+	var decoded map[string]interface{}
+	entries := ring.Entries()
+	if err := json.Unmarshal([]byte(entries[len(entries)-1]), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	AssertEquals(t, TestPID, decoded["pid"], "JSONFormatter: wrong pid")
+	AssertEquals(t, "local", decoded["kind"], "JSONFormatter: wrong kind")
+	AssertEquals(t, "INFO", decoded["priority"], "JSONFormatter: wrong priority")
+	AssertEquals(t, "value", decoded["attrs"].(map[string]interface{})["key"], "JSONFormatter: wrong attribute")
+	AssertEquals(t, float64(2), decoded["clock"].(map[string]interface{})[TestPID], "JSONFormatter: wrong clock")
+}
+
+func TestEventKinds(t *testing.T) {
+
+	formatter := &recordingFormatter{}
+	config := GetDefaultConfig()
+	config.Formatter = formatter
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	packed := gv.PrepareSend("TestMessage1", 1337, opts)
+	var response int
+	gv.UnpackReceive("TestMessage2", packed, &response, opts)
+	gv.LogLocalEvent("TestMessage3", opts)
+
+	kinds := []EventKind{SystemEvent, SendEvent, ReceiveEvent, LocalEvent}
+	AssertEquals(t, len(kinds), len(formatter.events), "Events: wrong number of events")
+	for i, kind := range kinds {
+		AssertEquals(t, kind, formatter.events[i].Kind, "Events: wrong kind")
+		n, _ := formatter.events[i].VC.FindTicks(TestPID)
+		AssertEquals(t, uint64(i+1), n, "Events: wrong clock")
+	}
+}
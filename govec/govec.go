@@ -3,14 +3,17 @@ package govec
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
+	"reflect"
 	"sync"
 	"time"
 
+	"github.com/DistributedClocks/GoVector/govec/itc"
 	"github.com/DistributedClocks/GoVector/govec/vclock"
 	ct "github.com/daviddengcn/go-colortext"
 	"github.com/vmihailenco/msgpack/v5"
@@ -22,6 +25,9 @@ var (
 	_             msgpack.CustomDecoder = (*VClockPayload)(nil)
 )
 
+// warnings reports misconfigurations, whatever logToTerminal
+var warnings = log.New(os.Stderr, "[GoVector]:", 0)
+
 // LogPriority controls the minimum priority of logging events which
 // will be logged.
 type LogPriority int
@@ -64,6 +70,12 @@ type GoLogConfig struct {
 	PrintOnScreen bool
 	// AppendLog determines to continue writing to a log from a prior execution.
 	AppendLog bool
+	// AppendRecovery decides how the clock carries on from the log of
+	// a prior execution when AppendLog is set. A clock loaded from
+	// ClockStore takes precedence over ResumeClock, a warning being
+	// printed, while NewIdentity carries on under the identity the
+	// clock was saved with. See AppendRecovery
+	AppendRecovery AppendRecovery
 	// UseTimestamps determines to log real time timestamps for TSVis
 	UseTimestamps bool
 	// EncodingStrategy for customizable interoperability
@@ -76,19 +88,87 @@ type GoLogConfig struct {
 	Priority LogPriority
 	// InitialVC is the initial vector clock value, nil by default
 	InitialVC vclock.VClock
+	// Sinks are additional destinations for logging events, written
+	// to alongside the log file if LogToFile is set
+	Sinks []Sink
+	// Formatter turns logging events into log entries. By default
+	// events are formatted for ShiViz, or TSViz if UseTimestamps is set
+	Formatter Formatter
+	// Clock selects the logical clock sent along with messages, all
+	// communicating GoLogs must use the same one. See ClockType
+	Clock ClockType
+	// InitialStamp is the stamp of a process using IntervalTreeClock:
+	// itc.Seed() for the first process, or else a stamp forked by
+	// another process with GoLog.ForkStamp. It must be set, as
+	// processes sharing the same stamp share the same identity
+	InitialStamp itc.Stamp
+	// PrunePolicy, if set, removes entries from the vector clock after
+	// every event. A policy with an Observe(vclock.VClock) method, such
+	// as vclock.Stale, is shown the clock before pruning. A sent
+	// message carries the clock before pruning. See
+	// vclock.VClock.Prune for the resulting caveats
+	PrunePolicy vclock.PrunePolicy
+	// DeltaEncoding makes messages sent with PrepareSendTo carry only
+	// the entries of the vector clock which changed since the previous
+	// message to the same peer. Channels between peers must be
+	// reliable and FIFO
+	DeltaEncoding bool
+	// DeltaResync bounds the number of delta encoded messages sent to
+	// a peer between two messages carrying the full clock, 0 for no
+	// bound. The first message to a peer always carries the full clock
+	DeltaResync int
+	// Rotation controls the rotation of the log file, which is never
+	// rotated by default. See RotatingFileSink
+	Rotation RotationPolicy
+	// Async makes log entries be written by a background goroutine,
+	// they are written synchronously by default. See AsyncPolicy
+	Async AsyncPolicy
+	// FlushOnSignal makes the GoLog close itself upon SIGINT or
+	// SIGTERM, logging a final event and writing every buffered entry
+	// before the process terminates. See GoLog.Close
+	FlushOnSignal bool
+	// ClockStore, if set, persists the vector clock, which is reloaded
+	// in place of InitialVC when a clock was saved. See ClockStore
+	ClockStore ClockStore
+	// CheckpointInterval is the number of clock ticks between two
+	// saves to the ClockStore, every tick being saved if 0. The clock
+	// is saved on Close too. As a crash loses up to
+	// CheckpointInterval-1 ticks, a reloaded clock is advanced by as
+	// many ticks, and saved, so that it never goes back to clocks the
+	// crashed process may have sent
+	CheckpointInterval int
+	// OnSaveError, if set, is called with the error of kind
+	// ErrClockSave of every failed save to the ClockStore but the
+	// final one, returned by Close. It is called with the GoLog locked
+	// and must not use it. The errors are printed if it is not set
+	OnSaveError func(err error)
 }
 
 // GetDefaultConfig returns the default GoLogConfig with default values
 // for various fields.
 func GetDefaultConfig() GoLogConfig {
 	config := GoLogConfig{
-		Buffered:      false,
-		PrintOnScreen: false,
-		AppendLog:     false,
-		UseTimestamps: false,
-		LogToFile:     true,
-		Priority:      INFO,
-		InitialVC:     nil,
+		Buffered:           false,
+		PrintOnScreen:      false,
+		AppendLog:          false,
+		AppendRecovery:     RestartClock,
+		UseTimestamps:      false,
+		LogToFile:          true,
+		Priority:           INFO,
+		InitialVC:          nil,
+		Sinks:              nil,
+		Formatter:          nil,
+		Clock:              VectorClock,
+		InitialStamp:       itc.Stamp{},
+		PrunePolicy:        nil,
+		DeltaEncoding:      false,
+		DeltaResync:        0,
+		Rotation:           RotationPolicy{},
+		Async:              AsyncPolicy{},
+		FlushOnSignal:      false,
+		ClockStore:         nil,
+		CheckpointInterval: 0,
+		OnSaveError:        nil,
 	}
 	return config
 }
@@ -97,6 +177,8 @@ func GetDefaultConfig() GoLogConfig {
 type GoLogOptions struct {
 	// The Log priority for this event
 	Priority LogPriority
+	// Attributes attached to this event
+	Attrs []Attr
 }
 
 // GetDefaultLogOptions returns the default GoLogOptions with default values
@@ -115,6 +197,15 @@ func (o *GoLogOptions) SetPriority(Priority LogPriority) GoLogOptions {
 	return opts
 }
 
+// SetAttr returns a new GoLogOptions object with the attribute key set
+// to value in addition to the attributes of the callee. Follows the
+// builder pattern.
+func (o *GoLogOptions) SetAttr(key string, value interface{}) GoLogOptions {
+	opts := *o
+	opts.Attrs = append(append([]Attr(nil), o.Attrs...), Attr{Key: key, Value: value})
+	return opts
+}
+
 // VClockPayload is the data structure that is actually end on the wire
 type VClockPayload struct {
 	Pid     string
@@ -209,11 +300,7 @@ func (d *VClockPayload) DecodeMsgpack(dec *msgpack.Decoder) error {
 		}
 		vcMap[key] = value
 	}
-	err = dec.DecodeMulti(&d.Pid, &d.Payload, &d.VcMap)
 	d.VcMap = vcMap
-	if err != nil {
-		return err
-	}
 
 	return nil
 }
@@ -230,25 +317,53 @@ type GoLog struct {
 	// Local vector clock in bytes
 	currentVC vclock.VClock
 
+	// Clock sent in place of the vector clock, nil if the vector
+	// clock is sent
+	clock altClock
+
+	// Processes whose entries are removed from the vector clock
+	retired vclock.Retired
+
+	// Policy removing further entries from the vector clock
+	prunePolicy vclock.PrunePolicy
+
+	// If true messages sent to a peer carry delta encoded clocks
+	delta bool
+
+	// Maximum number of delta encoded messages between full clocks
+	deltaResync int
+
+	// Clocks last sent to every peer, for delta encoding
+	peers map[string]*peerState
+
 	// Flag to Printf the logs made by Local Program
 	printonscreen bool
 
-	// If true GoLog will write to a file
+	// If true GoLog will write logging events to its sinks
 	logging bool
 
+	// If true GoLog will write to a file
+	logtofile bool
+
 	// If true logs are buffered in memory and flushed to disk upon
 	// calling flush. Logs can be lost if a program stops prior to
 	// flushing buffered logs.
 	buffered bool
 
-	// Flag to include timestamps when logging events
-	usetimestamps bool
+	// Formatter turning events into log entries
+	formatter Formatter
 
 	// Flag to indicate if the log file will contain multiple executions
 	appendLog bool
 
-	// Flag to indicate if the message broadcast is on
-	broadcast bool
+	// Rotation policy of the log file
+	rotation RotationPolicy
+
+	// Sender of the broadcast started by StartBroadcast, nil if none
+	// is on
+	broadcast *broadcastSender
+	// Held from StartBroadcast to StopBroadcast
+	broadcastMutex sync.Mutex
 
 	// Priority level at which all events are logged
 	priority LogPriority
@@ -256,8 +371,30 @@ type GoLog struct {
 	// Logfile name
 	logfile string
 
-	// buffered string
-	output string
+	// buffered log entries
+	output [][]byte
+
+	// destinations of the log entries
+	sinks []Sink
+
+	// background writer of the log entries, nil if they are written
+	// synchronously
+	async *asyncWriter
+
+	// Flag to indicate if the GoLog was closed
+	closed bool
+
+	// store persisting the clock, saved every checkpointInterval ticks
+	store              ClockStore
+	checkpointInterval int
+	// number of ticks not saved to the store yet
+	unsaved int
+	// reports the failed saves to the store
+	onSaveError func(err error)
+
+	// signals closing the GoLog, and closed to stop waiting for them
+	signals     chan os.Signal
+	stopSignals chan struct{}
 
 	// encoding and decoding strategies for network messages
 	encodingStrategy func(interface{}) ([]byte, error)
@@ -272,7 +409,22 @@ type GoLog struct {
 // InitGoVector returns a GoLog which generates a logs prefixed with
 // processid, to a file name logfilename.log. Any old log with the same
 // name will be trucated. Config controls logging options. See GoLogConfig for more details.
+// InitGoVector panics if config is invalid, see InitGoVectorE.
 func InitGoVector(processid string, logfilename string, config GoLogConfig) *GoLog {
+	gv, err := InitGoVectorE(processid, logfilename, config)
+	if err != nil {
+		panic(err)
+	}
+	return gv
+}
+
+// InitGoVectorE is like InitGoVector, but returns an error of kind
+// ErrConfig if config is invalid: IntervalTreeClock is selected
+// without an InitialStamp.
+func InitGoVectorE(processid string, logfilename string, config GoLogConfig) (*GoLog, error) {
+	if config.Clock == IntervalTreeClock && config.InitialStamp.IsAnonymous() {
+		return nil, newError(ErrConfig, errors.New("IntervalTreeClock requires an InitialStamp, itc.Seed() for the first process"))
+	}
 
 	gv := &GoLog{}
 	gv.pid = processid
@@ -286,12 +438,25 @@ func InitGoVector(processid string, logfilename string, config GoLogConfig) *GoL
 
 	//Set parameters from config
 	gv.printonscreen = config.PrintOnScreen
-	gv.usetimestamps = config.UseTimestamps
+	gv.clock = newAltClock(config)
+	gv.formatter = config.Formatter
+	if gv.formatter == nil {
+		// Hybrid clocks always provide timestamps consistent with causality
+		_, hybrid := gv.clock.(*hybridClock)
+		gv.formatter = ShiVizFormatter{UseTimestamps: config.UseTimestamps || hybrid}
+	}
 	gv.priority = config.Priority
-	gv.logging = config.LogToFile
+	gv.retired = vclock.NewRetired()
+	gv.prunePolicy = config.PrunePolicy
+	gv.delta = config.DeltaEncoding
+	gv.deltaResync = config.DeltaResync
+	gv.peers = make(map[string]*peerState)
+	gv.logging = config.LogToFile || len(config.Sinks) > 0
+	gv.logtofile = config.LogToFile
 	gv.buffered = config.Buffered
 	gv.appendLog = config.AppendLog
-	gv.output = ""
+	gv.rotation = config.Rotation
+	gv.sinks = append([]Sink(nil), config.Sinks...)
 
 	// Use the default encoder/decoder. As of July 2017 this is msgPack.
 	if config.EncodingStrategy == nil || config.DecodingStrategy == nil {
@@ -307,64 +472,115 @@ func InitGoVector(processid string, logfilename string, config GoLogConfig) *GoL
 		vc1 = vclock.New()
 		vc1.Set(processid, 0)
 	} else {
-		vc1 = config.InitialVC
+		vc1 = config.InitialVC.Copy()
+	}
+	gv.store = config.ClockStore
+	gv.checkpointInterval = config.CheckpointInterval
+	gv.onSaveError = config.OnSaveError
+	restored := false
+	if gv.store != nil {
+		saved, err := gv.store.Load()
+		if err != nil {
+			gv.logger.Println("Could not load the saved clock:", err)
+		} else if saved != nil {
+			vc1 = saved.Copy()
+			restored = true
+		}
 	}
 	gv.currentVC = vc1
+	if restored {
+		if config.AppendRecovery == NewIdentity {
+			// The clock was saved under the identity of the execution
+			gv.restoreIdentity()
+		}
+		if _, found := vc1.FindTicks(gv.pid); !found {
+			vc1.Set(gv.pid, 0)
+		}
+		if gv.checkpointInterval > 1 {
+			ticks, _ := vc1.FindTicks(gv.pid)
+			vc1.Set(gv.pid, ticks+uint64(gv.checkpointInterval-1))
+			gv.reportSaveError(gv.saveClock())
+		}
+	}
 
 	//Starting File IO . If Log exists, Log Will be deleted and A New one will be created
 	logname := logfilename + "-Log.txt"
 	gv.logfile = logname
+	if gv.logging && gv.logtofile && gv.appendLog {
+		if !restored {
+			gv.recoverFromLog(config.AppendRecovery)
+		} else if config.AppendRecovery == ResumeClock {
+			warnings.Println("Warning: ResumeClock ignored, the clock is loaded from the ClockStore")
+		}
+	}
 	if gv.logging {
 		gv.prepareLogFile()
+		if config.Async.QueueSize > 0 {
+			gv.async = newAsyncWriter(config.Async, gv.sinks)
+		}
+		if config.FlushOnSignal {
+			gv.closeOnSignal()
+		}
 	}
 
-	return gv
+	return gv, nil
 }
 
 func (gv *GoLog) prepareLogFile() {
-	_, err := os.Stat(gv.logfile)
-	if err == nil {
-		if !gv.appendLog {
+	if gv.logtofile {
+		_, err := os.Stat(gv.logfile)
+		exists := err == nil
+		if exists && !gv.appendLog {
 			gv.logger.Println(gv.logfile, "exists! ... Deleting ")
-			os.Remove(gv.logfile)
+		}
+
+		// Create directory path to log if it doesn't exist.
+		if err := os.MkdirAll(filepath.Dir(gv.logfile), 0750); err != nil {
+			gv.logger.Println(err)
+		}
+
+		// Open the log once, it stays open for the lifetime of the GoLog
+		var file Sink
+		if gv.rotation.enabled() {
+			file, err = NewRotatingFileSink(gv.logfile, gv.appendLog, gv.rotation)
 		} else {
+			file, err = NewFileSink(gv.logfile, gv.appendLog)
+		}
+		if err != nil {
+			gv.logger.Println(err)
+		} else {
+			gv.sinks = append([]Sink{file}, gv.sinks...)
+		}
+
+		if exists && gv.appendLog {
 			executionnumber := time.Now().Format(time.UnixDate)
 			gv.logger.Println("Execution Number is  ", executionnumber)
 			executionstring := "=== Execution #" + executionnumber + "  ==="
-			gv.logThis(executionstring, "", "", gv.priority)
+			gv.logThis(gv.newSystemEvent(executionstring, false))
 			return
 		}
 	}
-	// Create directory path to log if it doesn't exist.
-	if err := os.MkdirAll(filepath.Dir(gv.logfile), 0750); err != nil {
-		gv.logger.Println(err)
-	}
-
-	//Creating new Log
-	file, err := os.Create(gv.logfile)
-	if err != nil {
-		gv.logger.Println(err)
-	}
-
-	file.Close()
 
 	if gv.appendLog {
 		executionnumber := time.Now().Format(time.UnixDate)
 		gv.logger.Println("Execution Number is  ", executionnumber)
 		executionstring := "=== Execution #" + executionnumber + "  ==="
-		gv.logThis(executionstring, "", "", gv.priority)
+		gv.logThis(gv.newSystemEvent(executionstring, false))
 	}
 
-	gv.currentVC.Tick(gv.pid)
-	ok := gv.logThis("Initialization Complete", gv.pid, gv.currentVC.ReturnVCString(), gv.priority)
+	gv.tickClock()
+	ok := gv.logThis(gv.newSystemEvent("Initialization Complete", true))
 	if ok == false {
 		gv.logger.Println("Something went Wrong, Could not Log!")
 	}
 }
 
-// GetCurrentVC returns the current vector clock
+// GetCurrentVC returns a copy of the current vector clock, which is
+// safe to use while other goroutines keep logging events.
 func (gv *GoLog) GetCurrentVC() vclock.VClock {
-	return gv.currentVC
+	gv.mutex.RLock()
+	defer gv.mutex.RUnlock()
+	return gv.currentVC.Copy()
 }
 
 // setEncoderDecoder Sets the Encoding and Decoding functions which are to be used by the logger
@@ -390,7 +606,9 @@ func defaultDecoder(buf []byte, payload interface{}) error {
 // call to the function Flush.  Note: Buffered writes are automatically
 // disabled.
 func (gv *GoLog) EnableBufferedWrites() {
+	gv.mutex.Lock()
 	gv.buffered = true
+	gv.mutex.Unlock()
 }
 
 // DisableBufferedWrites disables buffered writes to the log file. All
@@ -398,29 +616,59 @@ func (gv *GoLog) EnableBufferedWrites() {
 // immediately. Writes all the existing log messages that haven't been
 // written to Log file yet.
 func (gv *GoLog) DisableBufferedWrites() {
+	gv.mutex.Lock()
 	gv.buffered = false
-	if gv.output != "" {
-		gv.Flush()
+	if len(gv.output) > 0 {
+		gv.flush()
 	}
+	gv.mutex.Unlock()
 }
 
-// Flush writes the log messages stored in the buffer to the Log File.
-// This function should be used by the application to also force writes
-// in the case of interrupts and crashes.   Note: Calling Flush when
-// BufferedWrites is disabled is essentially a no-op.
+// Flush writes the log messages stored in the buffer to the Log File
+// and every other configured Sink. Close flushes the log too, and so
+// does a GoLog configured with FlushOnSignal upon interrupts.   Note:
+// Calling Flush when BufferedWrites is disabled is essentially a
+// no-op, unless the GoLog is asynchronous: Flush then waits for every
+// queued entry to be written.
 func (gv *GoLog) Flush() bool {
-	complete := true
-	file, err := os.OpenFile(gv.logfile, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	complete := gv.flush()
+	if gv.async != nil && !gv.async.flush() {
 		complete = false
 	}
-	defer file.Close()
+	return complete
+}
+
+// flush implements Flush, the caller must hold the mutex. The entries
+// of an asynchronous GoLog are only queued.
+func (gv *GoLog) flush() bool {
+	if gv.closed {
+		complete := len(gv.output) == 0
+		gv.output = nil
+		return complete
+	}
+	if gv.async != nil {
+		complete := true
+		for _, entry := range gv.output {
+			if !gv.async.push(entry) {
+				complete = false
+			}
+		}
+		gv.output = nil
+		return complete
+	}
 
-	if _, err = file.WriteString(gv.output); err != nil {
-		complete = false
+	complete := len(gv.sinks) > 0 || len(gv.output) == 0
+	for _, entry := range gv.output {
+		for _, sink := range gv.sinks {
+			if _, err := sink.Write(entry); err != nil {
+				complete = false
+			}
+		}
 	}
 
-	gv.output = ""
+	gv.output = nil
 	return complete
 }
 
@@ -433,45 +681,77 @@ func (gv *GoLog) printColoredMessage(LogMessage string, Priority LogPriority) {
 	fmt.Println(LogMessage)
 }
 
-// Logs a message along with a processID and a vector clock, the VCString
-// must be a valid vector clock, true is returned on success. logThis
-// is the innermost logging function internally used by all other
-// logging functions
-func (gv *GoLog) logThis(Message string, ProcessID string, VCString string, Priority LogPriority) bool {
-	var (
-		complete = true
-		buffer   bytes.Buffer
-	)
-	if gv.usetimestamps {
-		buffer.WriteString(strconv.FormatInt(time.Now().UnixNano(), 10))
-		buffer.WriteString(" ")
-	}
-	buffer.WriteString(ProcessID)
-	buffer.WriteString(" ")
-	buffer.WriteString(VCString)
-	buffer.WriteString("\n")
-	buffer.WriteString(Message)
-	buffer.WriteString("\n")
-	output := buffer.String()
-
-	gv.output += output
+// newEvent returns an event of the given kind stamped with the
+// current vector clock.
+func (gv *GoLog) newEvent(mesg string, kind EventKind, opts GoLogOptions) *Event {
+	e := &Event{
+		Pid:      gv.pid,
+		VC:       gv.currentVC.Copy(),
+		Priority: opts.Priority,
+		Time:     time.Now(),
+		Message:  mesg,
+		Attrs:    opts.Attrs,
+		Kind:     kind,
+	}
+	if gv.clock != nil {
+		gv.clock.annotate(e)
+	}
+	return e
+}
+
+// newSystemEvent returns an event logged by GoVector itself, stamped
+// with the current vector clock if stamped is set.
+func (gv *GoLog) newSystemEvent(mesg string, stamped bool) *Event {
+	e := &Event{
+		Priority: gv.priority,
+		Time:     time.Now(),
+		Message:  mesg,
+		Kind:     SystemEvent,
+	}
+	if stamped {
+		e.Pid = gv.pid
+		e.VC = gv.currentVC.Copy()
+		if gv.clock != nil {
+			gv.clock.annotate(e)
+		}
+	}
+	return e
+}
+
+// Logs an event by formatting it into a log entry which is written to
+// every sink, true is returned on success. logThis is the innermost
+// logging function internally used by all other logging functions
+func (gv *GoLog) logThis(e *Event) bool {
+	if gv.closed {
+		return false
+	}
+	complete := true
+	entry, err := gv.formatter.Format(e)
+	if err != nil {
+		gv.logger.Println(err)
+		return false
+	}
+
+	gv.output = append(gv.output, entry)
 	if !gv.buffered {
-		complete = gv.Flush()
+		complete = gv.flush()
 	}
 
 	if gv.printonscreen == true {
-		gv.printColoredMessage(Message, Priority)
+		message := e.Message
+		if e.Kind != SystemEvent {
+			message = prefixLookup[e.Priority] + " " + message
+		}
+		gv.printColoredMessage(message, e.Priority)
 	}
 	return complete
 }
 
 // logWriteWrapper is a helper function for wrapping common logging
 // behaviour associated with logThis
-func (gv *GoLog) logWriteWrapper(mesg, errMesg string, priority LogPriority) (success bool) {
+func (gv *GoLog) logWriteWrapper(mesg, errMesg string, kind EventKind, opts GoLogOptions) (success bool) {
 	if gv.logging == true {
-		prefix := prefixLookup[priority]
-		wrappedMesg := prefix + " " + mesg
-		success = gv.logThis(wrappedMesg, gv.pid, gv.currentVC.ReturnVCString(), priority)
+		success = gv.logThis(gv.newEvent(mesg, kind, opts))
 		if !success {
 			gv.logger.Println(errMesg)
 		}
@@ -481,11 +761,63 @@ func (gv *GoLog) logWriteWrapper(mesg, errMesg string, priority LogPriority) (su
 
 // Increment GoVectors local clock by 1
 func (gv *GoLog) tickClock() {
+	gv.advanceClock()
+	gv.checkpoint()
+}
+
+// advanceClock implements tickClock without saving the clock
+func (gv *GoLog) advanceClock() {
 	_, found := gv.currentVC.FindTicks(gv.pid)
 	if !found {
 		gv.logger.Println("Couldn't find this process's id in its own vector clock!")
 	}
 	gv.currentVC.Tick(gv.pid)
+	if gv.clock != nil {
+		gv.clock.tick()
+	}
+	gv.pruneClock()
+}
+
+// pruneClock removes the entries of retired processes and those
+// rejected by the prune policy from the local clock, always keeping
+// the entry of the local process
+func (gv *GoLog) pruneClock() {
+	ticks, found := gv.currentVC.FindTicks(gv.pid)
+	gv.currentVC.Prune(gv.retired)
+	if gv.prunePolicy != nil {
+		if o, ok := gv.prunePolicy.(interface{ Observe(vclock.VClock) }); ok {
+			o.Observe(gv.currentVC)
+		}
+		gv.currentVC.Prune(gv.prunePolicy)
+	}
+	if found {
+		gv.currentVC.Set(gv.pid, ticks)
+	}
+}
+
+// RetireProcess removes the entry of pid, a process which permanently
+// left the system, from the vector clock, and from every clock merged
+// into it from now on, so that messages stop carrying it. The entry of
+// the local process cannot be retired. See vclock.VClock.Prune for the
+// resulting caveats.
+func (gv *GoLog) RetireProcess(pid string) {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	if pid == gv.pid {
+		return
+	}
+	gv.retired[pid] = true
+	gv.pruneClock()
+}
+
+// Enabled reports whether events of the given priority are logged,
+// events of a lower priority being filtered out along with their
+// clocks: PrepareSendE then returns no message and UnpackReceiveE
+// decodes nothing.
+func (gv *GoLog) Enabled(priority LogPriority) bool {
+	gv.mutex.RLock()
+	defer gv.mutex.RUnlock()
+	return priority >= gv.priority
 }
 
 // LogLocalEvent implements LogLocalEvent with priority
@@ -496,11 +828,20 @@ func (gv *GoLog) tickClock() {
 // * LogMessage (string) : Message to be logged
 // * Priority (LogPriority) : Priority at which the message is to be logged
 func (gv *GoLog) LogLocalEvent(mesg string, opts GoLogOptions) (logSuccess bool) {
+	_, logSuccess = gv.logLocalEvent(mesg, opts)
+	return
+}
+
+// logLocalEvent implements LogLocalEvent and additionally returns a
+// copy of the vector clock the event was logged with, or nil if the
+// event was filtered out by its priority.
+func (gv *GoLog) logLocalEvent(mesg string, opts GoLogOptions) (vc vclock.VClock, logSuccess bool) {
 	logSuccess = true
 	gv.mutex.Lock()
 	if opts.Priority >= gv.priority {
 		gv.tickClock()
-		logSuccess = gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log LocalEvent!", opts.Priority)
+		logSuccess = gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log LocalEvent!", LocalEvent, opts)
+		vc = gv.currentVC.Copy()
 	}
 	gv.mutex.Unlock()
 	return
@@ -515,47 +856,115 @@ func (gv *GoLog) LogLocalEvent(mesg string, opts GoLogOptions) (logSuccess bool)
 // the clock using gob support and return the new byte array that should
 // be sent onwards using the Send Command
 func (gv *GoLog) PrepareSend(mesg string, buf interface{}, opts GoLogOptions) (encodedBytes []byte) {
-	//Converting Vector Clock from Bytes and Updating the gv clock
-	if !gv.broadcast {
-		gv.mutex.Lock()
-		if opts.Priority >= gv.priority {
-			gv.tickClock()
+	encodedBytes, err := gv.PrepareSendE(mesg, buf, opts)
+	if err != nil {
+		gv.logger.Println(err.Error())
+	}
+	return
+}
+
+// PrepareSendE behaves like PrepareSend but reports failures to the
+// caller. If the message cannot be encoded an error of kind ErrEncode
+// is returned and the local clock is left untouched. If the send event
+// cannot be written to the log an error of kind ErrLogWrite is
+// returned along with the encoded message, which is still valid and
+// may be sent.
+func (gv *GoLog) PrepareSendE(mesg string, buf interface{}, opts GoLogOptions) (encodedBytes []byte, err error) {
+	return gv.prepareSend(nil, "", mesg, buf, opts)
+}
 
-			gv.logWriteWrapper(mesg, "Something went wrong, could not log prepare send", opts.Priority)
+// prepareSend implements PrepareSendE. If incoming is not nil it is
+// merged into the local clock before the send event is logged. If peer
+// is not empty the message is sent to peer, see PrepareSendTo.
+func (gv *GoLog) prepareSend(incoming vclock.VClock, peer string, mesg string, buf interface{}, opts GoLogOptions) (encodedBytes []byte, err error) {
+	gv.mutex.Lock()
+	if b := gv.broadcast; b != nil {
+		// Broadcast: do not tick the clock or log an event as all been
+		// done by StartBroadcast. StopBroadcast takes the lock, so the
+		// broadcast cannot be over
+		defer gv.mutex.Unlock()
+		return b.PrepareSend(buf)
+	}
+	gv.mutex.Unlock()
+	return gv.prepareSendWith(incoming, mesg, opts, func() ([]byte, error) {
+		return gv.encodePayload(peer, buf)
+	})
+}
 
-			d := VClockPayload{Pid: gv.pid, VcMap: gv.currentVC.GetMap(), Payload: buf}
+// prepareSendWith implements prepareSend, encoding the message with
+// encode once the clock has ticked.
+func (gv *GoLog) prepareSendWith(incoming vclock.VClock, mesg string, opts GoLogOptions, encode func() ([]byte, error)) (encodedBytes []byte, err error) {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	if opts.Priority < gv.priority {
+		return
+	}
 
-			// encode the Clock Payload
-			var err error
-			encodedBytes, err = gv.encodingStrategy(&d)
-			if err != nil {
-				gv.logger.Println(err.Error())
-			}
+	snapshot := gv.currentVC.Copy()
+	if incoming != nil && gv.clock == nil {
+		gv.currentVC.Merge(incoming)
+		gv.currentVC.Prune(gv.retired)
+	}
+	// The clock is neither pruned by the policy nor saved until the
+	// payload is encoded, so that a failed send leaves no trace
+	gv.currentVC.Tick(gv.pid)
+	if gv.clock != nil {
+		gv.clock.tick()
+	}
+	encodedBytes, err = encode()
+	if err != nil {
+		// Roll the clock back, merged entries included, so that no gap
+		// appears in the log. An alternative clock only needs to be
+		// monotonic, so it is not rolled back
+		gv.currentVC = snapshot
+		return nil, err
+	}
+	gv.checkpoint()
 
-			// return encodedBytes which can be sent off and received on the other end!
-		}
-		gv.mutex.Unlock()
+	if !gv.logWriteWrapper(mesg, "Something went wrong, could not log prepare send", SendEvent, opts) && gv.logging {
+		err = newError(ErrLogWrite, nil)
+	}
+	gv.pruneClock()
 
-	} else {
-		// Broadcast: do not acquire the lock, tick the clock or log an event as
-		// all been done by StartBroadcast
-		d := VClockPayload{Pid: gv.pid, VcMap: gv.currentVC.GetMap(), Payload: buf}
+	// return encodedBytes which can be sent off and received on the other end!
+	return
+}
 
-		var err error
-		encodedBytes, err = gv.encodingStrategy(&d)
-		if err != nil {
-			gv.logger.Println(err.Error())
+// encodePayload wraps buf along with the current clock and encodes it
+// with the configured encoding strategy. If peer is not empty and delta
+// encoding is enabled, only the entries peer does not know of are
+// sent.
+func (gv *GoLog) encodePayload(peer string, buf interface{}) ([]byte, error) {
+	var d interface{}
+	var sent vclock.VClock
+	full := true
+	if gv.clock != nil {
+		d = gv.clock.envelope(gv.pid, buf)
+	} else {
+		sent = gv.currentVC
+		if gv.delta && peer != "" {
+			sent, full = gv.deltaClock(peer)
 		}
+		d = &VClockPayload{Pid: gv.pid, VcMap: sent.GetMap(), Payload: buf}
 	}
-	return
+	encodedBytes, err := gv.encodingStrategy(d)
+	if err != nil {
+		return nil, newError(ErrEncode, err)
+	}
+	if sent != nil && gv.delta && peer != "" {
+		gv.sentTo(peer, sent, full)
+	}
+	return encodedBytes, nil
 }
 
-func (gv *GoLog) mergeIncomingClock(mesg string, e VClockPayload, Priority LogPriority) {
+func (gv *GoLog) mergeIncomingClock(mesg string, e VClockPayload, opts GoLogOptions) bool {
 	// First, tick the local clock
-	gv.tickClock()
+	gv.advanceClock()
 	gv.currentVC.Merge(e.VcMap)
+	gv.pruneClock()
+	gv.checkpoint()
 
-	gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log!", Priority)
+	return gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log!", ReceiveEvent, opts)
 }
 
 // UnpackReceive is used to unmarshall network data into local structures.
@@ -566,40 +975,166 @@ func (gv *GoLog) mergeIncomingClock(mesg string, e VClockPayload, Priority LogPr
 // a packet. It unpacks the data by the program, the vector clock. It
 // updates vector clock and logs it. and returns the user data
 func (gv *GoLog) UnpackReceive(mesg string, buf []byte, unpack interface{}, opts GoLogOptions) {
+	if err := gv.UnpackReceiveE(mesg, buf, unpack, opts); err != nil {
+		gv.logger.Println(err.Error())
+	}
+}
+
+// UnpackReceiveE behaves like UnpackReceive but reports failures to
+// the caller. If buf cannot be decoded an error of kind ErrDecode is
+// returned, and if the decoded clock does not contain the sender's
+// process id an error of kind ErrMissingPid is returned. In both cases
+// the local clock and the value unpack points to are left untouched,
+// and nothing is logged. If the
+// receive event cannot be written to the log an error of kind
+// ErrLogWrite is returned after the clocks have been merged.
+func (gv *GoLog) UnpackReceiveE(mesg string, buf []byte, unpack interface{}, opts GoLogOptions) error {
+	_, err := gv.unpackReceive(mesg, buf, unpack, opts)
+	return err
+}
+
+// unpackReceive implements UnpackReceiveE and additionally returns a
+// copy of the local clock after the receive, or nil if nothing was
+// merged.
+func (gv *GoLog) unpackReceive(mesg string, buf []byte, unpack interface{}, opts GoLogOptions) (vclock.VClock, error) {
 	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	if opts.Priority < gv.priority {
+		return nil, nil
+	}
 
-	if opts.Priority >= gv.priority {
-		e := VClockPayload{}
-		e.Payload = unpack
+	if gv.clock != nil {
+		return gv.unpackReceiveAlt(mesg, buf, unpack, opts)
+	}
 
-		// Just use msgpack directly
-		err := gv.decodingStrategy(buf, &e)
-		if err != nil {
-			gv.logger.Println(err.Error())
-		}
+	staged, commit := stageUnpack(unpack)
+	e := VClockPayload{}
+	e.Payload = staged
 
-		// Increment and merge the incoming clock
-		gv.mergeIncomingClock(mesg, e, opts.Priority)
+	// Just use msgpack directly
+	if err := gv.decodingStrategy(buf, &e); err != nil {
+		return nil, newError(ErrDecode, err)
 	}
-	gv.mutex.Unlock()
+	if _, found := e.VcMap[e.Pid]; !found || e.Pid == "" {
+		return nil, newError(ErrMissingPid, nil)
+	}
+	commit()
+
+	// Increment and merge the incoming clock
+	if !gv.mergeIncomingClock(mesg, e, opts) && gv.logging {
+		return gv.currentVC.Copy(), newError(ErrLogWrite, nil)
+	}
+	return gv.currentVC.Copy(), nil
+}
 
+// stageUnpack returns a copy of the value unpack points to, for a
+// message to be decoded into, and a function storing the decoded copy
+// into unpack once the message is found valid. Values reached through
+// pointers held by the copy are shared with unpack, and may thus be
+// written by the decoding of an invalid message.
+func stageUnpack(unpack interface{}) (interface{}, func()) {
+	v := reflect.ValueOf(unpack)
+	if !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() {
+		return unpack, func() {}
+	}
+	staged := reflect.New(v.Elem().Type())
+	staged.Elem().Set(v.Elem())
+	return staged.Interface(), func() { v.Elem().Set(staged.Elem()) }
 }
 
-// StartBroadcast allows to use vector clocks in the context of casual broadcasts
-// sent via RPC. Any call to StartBroadcast must have a corresponding call to 
-// StopBroadcast, otherwise a deadlock will occur. All RPC calls made in-between 
-// the calls to StartBroadcast and StopBroadcast will be logged as a single event, 
-// will be sent out with the same vector clock and will represent broadcast messages
-// from the current process to the process pool.
-func (gv *GoLog) StartBroadcast(mesg string, opts GoLogOptions) {
+// unpackReceiveAlt implements unpackReceive for messages carrying an
+// alternative clock, which only ticks the local vector clock.
+func (gv *GoLog) unpackReceiveAlt(mesg string, buf []byte, unpack interface{}, opts GoLogOptions) (vclock.VClock, error) {
+	staged, commit := stageUnpack(unpack)
+	e := gv.clock.newEnvelope(staged)
+	if err := gv.decodingStrategy(buf, e); err != nil {
+		return nil, newError(ErrDecode, err)
+	}
+	if !gv.clock.receive(e) {
+		return nil, newError(ErrMissingPid, nil)
+	}
+	commit()
+	gv.currentVC.Tick(gv.pid)
+	gv.checkpoint()
+
+	if !gv.logWriteWrapper(mesg, "Something went Wrong, Could not Log!", ReceiveEvent, opts) && gv.logging {
+		return gv.currentVC.Copy(), newError(ErrLogWrite, nil)
+	}
+	return gv.currentVC.Copy(), nil
+}
+
+// PrepareSendHeader is meant to be used immediately before sending a
+// message whose payload is encoded by the application. Like
+// PrepareSend it ticks the clock and logs a send event, but it returns
+// only the encoded clock, to be carried in a header of the
+// application's own protocol (Kafka record headers, HTTP headers,
+// custom framing...) next to the untouched payload. The header is
+// consumed on the other end by UnpackReceiveHeader. Errors are reported
+// as by PrepareSendE.
+//
+// The header holds the varint encoded length of the process id, the
+// process id and the clock encoded by vclock.VClock.MarshalBinary.
+// With an alternative clock, see GoLogConfig.Clock, the header is the
+// clock envelope encoded with the encoding strategy.
+func (gv *GoLog) PrepareSendHeader(mesg string, opts GoLogOptions) ([]byte, error) {
+	return gv.prepareSendWith(nil, mesg, opts, gv.encodeHeader)
+}
+
+// UnpackReceiveHeader is meant to be used immediately after receiving
+// a message whose clock was produced by PrepareSendHeader. It merges
+// the clock in header into the local clock and logs a receive event.
+// Errors are reported as by UnpackReceiveE; when the header is
+// malformed the local clock is left untouched.
+func (gv *GoLog) UnpackReceiveHeader(mesg string, header []byte, opts GoLogOptions) error {
 	gv.mutex.Lock()
-	gv.tickClock()
-	gv.logWriteWrapper(mesg, "Something went wrong, could not log prepare send", opts.Priority)
-	gv.broadcast = true
+	defer gv.mutex.Unlock()
+	if opts.Priority < gv.priority {
+		return nil
+	}
+	if gv.clock != nil {
+		_, err := gv.unpackReceiveAlt(mesg, header, nil, opts)
+		return err
+	}
+
+	pid, vc, err := decodeHeader(header)
+	if err != nil {
+		return newError(ErrDecode, err)
+	}
+	if _, found := vc[pid]; !found || pid == "" {
+		return newError(ErrMissingPid, nil)
+	}
+	if !gv.mergeIncomingClock(mesg, VClockPayload{Pid: pid, VcMap: vc.GetMap()}, opts) && gv.logging {
+		return newError(ErrLogWrite, nil)
+	}
+	return nil
 }
 
-// StopBroadcast is called once all RPC calls of a message broadcast have been sent.
-func (gv *GoLog) StopBroadcast() {
-	gv.broadcast = false
-	gv.mutex.Unlock()
+// encodeHeader encodes the current clock as a header, see
+// PrepareSendHeader. The caller must hold the mutex.
+func (gv *GoLog) encodeHeader() ([]byte, error) {
+	if gv.clock != nil {
+		return gv.encodePayload("", nil)
+	}
+	vc, err := gv.currentVC.MarshalBinary()
+	if err != nil {
+		return nil, newError(ErrEncode, err)
+	}
+	header := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(gv.pid)+len(vc))
+	header = header[:binary.PutUvarint(header, uint64(len(gv.pid)))]
+	header = append(header, gv.pid...)
+	return append(header, vc...), nil
+}
+
+// decodeHeader decodes a header encoded by encodeHeader
+func decodeHeader(header []byte) (string, vclock.VClock, error) {
+	size, n := binary.Uvarint(header)
+	if n <= 0 || size > uint64(len(header)-n) {
+		return "", nil, vclock.ErrMalformedBinary
+	}
+	pid := string(header[n : n+int(size)])
+	var vc vclock.VClock
+	if err := vc.UnmarshalBinary(header[n+int(size):]); err != nil {
+		return "", nil, err
+	}
+	return pid, vc, nil
 }
@@ -1,7 +1,11 @@
 package govec
 
 import (
+	"bytes"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/DistributedClocks/GoVector/govec/vclock"
 	//"fmt"
@@ -98,6 +102,44 @@ func TestSendAndUnpackStrings(t *testing.T) {
 
 }
 
+func TestSendAndUnpackHeader(t *testing.T) {
+
+	sender := InitGoVector("Sender", "TestLogFile", GetDefaultConfig())
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	header, err := sender.PrepareSendHeader("TestMessage1", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, _ := sender.GetCurrentVC().FindTicks("Sender")
+	AssertEquals(t, uint64(2), n, "PrepareSendHeader: Clock value not incremented")
+
+	// The header holds the pid and the binary clock only, no payload
+	binaryVC, _ := sender.GetCurrentVC().MarshalBinary()
+	expected := append([]byte{byte(len("Sender"))}, "Sender"...)
+	expected = append(expected, binaryVC...)
+	AssertTrue(t, bytes.Equal(expected, header), "PrepareSendHeader: header is not compact")
+	AssertTrue(t, !bytes.Contains(header, []byte("Payload")), "PrepareSendHeader: header holds a payload field")
+
+	err = gv.UnpackReceiveHeader("TestMessage2", header, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vc := gv.GetCurrentVC()
+	n, _ = vc.FindTicks(TestPID)
+	AssertEquals(t, uint64(2), n, "UnpackReceiveHeader: Clock value not incremented")
+	n, _ = vc.FindTicks("Sender")
+	AssertEquals(t, uint64(2), n, "UnpackReceiveHeader: Clock not merged")
+
+	err = gv.UnpackReceiveHeader("TestMessage3", header[:len(header)/2], opts)
+	AssertTrue(t, errors.Is(err, ErrDecode), "UnpackReceiveHeader: expected a decode error")
+	n, _ = gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(2), n, "UnpackReceiveHeader: Clock value incremented on failure")
+}
+
 func TestBroadcast(t *testing.T) {
 
 	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
@@ -127,6 +169,253 @@ func TestBroadcast(t *testing.T) {
 	AssertEquals(t, uint64(3), n, "PrepareSend: Clock value incremented.")
 }
 
+func TestBroadcastSender(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	var packed [][]byte
+	var sender BroadcastSender
+	err := gv.Broadcast("TestBroadcast", opts, func(send BroadcastSender) error {
+		sender = send
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				buf, err := send.PrepareSend(i)
+				mutex.Lock()
+				if err == nil {
+					packed = append(packed, buf)
+				}
+				mutex.Unlock()
+			}(i)
+		}
+		// The GoLog is not locked during the broadcast
+		gv.LogLocalEvent("Concurrent event", opts)
+		wg.Wait()
+		return nil
+	})
+	AssertTrue(t, err == nil, "Broadcast: unexpected error")
+	AssertEquals(t, 5, len(packed), "BroadcastSender: encoding failed")
+
+	n, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(3), n, "Broadcast: expected a single send event")
+
+	for _, buf := range packed {
+		var response int
+		receiver := InitGoVector("Receiver", "TestLogFile", GetDefaultConfig())
+		receiver.UnpackReceive("TestMessage", buf, &response, opts)
+		n, _ := receiver.GetCurrentVC().FindTicks(TestPID)
+		AssertEquals(t, uint64(2), n, "BroadcastSender: wrong clock sent")
+	}
+
+	_, err = sender.PrepareSend(0)
+	AssertTrue(t, errors.Is(err, ErrBroadcastDone), "BroadcastSender: usable after the broadcast")
+
+	errSend := errors.New("send failed")
+	err = gv.Broadcast("TestBroadcast", opts, func(send BroadcastSender) error {
+		return errSend
+	})
+	AssertEquals(t, errSend, err, "Broadcast: error of send not returned")
+}
+
+func TestBroadcastPanic(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	var sender BroadcastSender
+	func() {
+		defer func() {
+			AssertTrue(t, recover() != nil, "Broadcast: panic not propagated")
+		}()
+		gv.Broadcast("TestBroadcast", opts, func(send BroadcastSender) error {
+			sender = send
+			panic("send failed")
+		})
+	}()
+
+	_, err := sender.PrepareSend(0)
+	AssertTrue(t, errors.Is(err, ErrBroadcastDone), "BroadcastSender: usable after a panic")
+	gv.LogLocalEvent("After the panic", opts)
+	n, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(3), n, "Broadcast: GoLog unusable after a panic")
+}
+
+func TestStartBroadcastConcurrent(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	sender := gv.StartBroadcast("TestBroadcast", opts)
+	done := make(chan bool)
+	go func() {
+		// Used to deadlock until StopBroadcast
+		gv.LogLocalEvent("Concurrent event", opts)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("StartBroadcast: GoLog locked during the broadcast")
+	}
+	packed := gv.PrepareSend("", 1337, opts)
+	broadcast, _ := sender.PrepareSend(1337)
+	gv.StopBroadcast()
+
+	n, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(3), n, "StartBroadcast: PrepareSend ticked the clock")
+
+	for _, buf := range [][]byte{packed, broadcast} {
+		receiver := InitGoVector("Receiver", "TestLogFile", GetDefaultConfig())
+		var response int
+		receiver.UnpackReceive("TestMessage", buf, &response, opts)
+		n, _ = receiver.GetCurrentVC().FindTicks(TestPID)
+		AssertEquals(t, uint64(2), n, "StartBroadcast: wrong clock sent")
+	}
+
+	_, err := sender.PrepareSend(0)
+	AssertTrue(t, errors.Is(err, ErrBroadcastDone), "BroadcastSender: usable after StopBroadcast")
+
+	// Without a broadcast, StopBroadcast does nothing
+	gv.StopBroadcast()
+}
+
+func TestRetireProcess(t *testing.T) {
+
+	sender := InitGoVector("sender", "TestLogFile", GetDefaultConfig())
+	receiver := InitGoVector("receiver", "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+	var response int
+
+	receiver.UnpackReceive("TestMessage1", sender.PrepareSend("TestMessage1", 1, opts), &response, opts)
+	_, found := receiver.GetCurrentVC().FindTicks("sender")
+	AssertTrue(t, found, "RetireProcess: sender not merged")
+
+	receiver.RetireProcess("sender")
+	receiver.RetireProcess("receiver")
+	AssertEquals(t, `{"receiver":2}`, receiver.GetCurrentVC().ReturnVCString(), "RetireProcess: wrong entries retired")
+
+	// Later messages do not bring the retired entry back
+	receiver.UnpackReceive("TestMessage2", sender.PrepareSend("TestMessage2", 2, opts), &response, opts)
+	AssertEquals(t, `{"receiver":3}`, receiver.GetCurrentVC().ReturnVCString(), "RetireProcess: retired entry merged")
+}
+
+func TestPrunePolicy(t *testing.T) {
+
+	config := GetDefaultConfig()
+	config.PrunePolicy = vclock.NewStale("receiver", 2)
+	receiver := InitGoVector("receiver", "TestLogFile", config)
+	sender := InitGoVector("sender", "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+	var response int
+
+	receiver.UnpackReceive("TestMessage1", sender.PrepareSend("TestMessage1", 1, opts), &response, opts)
+	receiver.LogLocalEvent("TestMessage2", opts)
+	AssertEquals(t, `{"receiver":3, "sender":2}`, receiver.GetCurrentVC().ReturnVCString(), "PrunePolicy: entry pruned too early")
+
+	receiver.LogLocalEvent("TestMessage3", opts)
+	AssertEquals(t, `{"receiver":4}`, receiver.GetCurrentVC().ReturnVCString(), "PrunePolicy: stale entry kept")
+}
+
+func TestPrepareSendEncodeError(t *testing.T) {
+
+	config := GetDefaultConfig()
+	config.EncodingStrategy = func(interface{}) ([]byte, error) {
+		return nil, errors.New("cannot encode")
+	}
+	config.DecodingStrategy = defaultDecoder
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	packed, err := gv.PrepareSendE("TestMessage1", 1337, opts)
+
+	AssertTrue(t, errors.Is(err, ErrEncode), "PrepareSendE: expected an encode error")
+	AssertTrue(t, packed == nil, "PrepareSendE: expected no encoded bytes")
+
+	n, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(1), n, "PrepareSendE: Clock value incremented on failure")
+}
+
+func TestUnpackReceiveDecodeError(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	var response int
+	err := gv.UnpackReceiveE("TestMessage1", []byte{0xc1, 0x00}, &response, opts)
+
+	AssertTrue(t, errors.Is(err, ErrDecode), "UnpackReceiveE: expected a decode error")
+
+	vc := gv.GetCurrentVC()
+	n, _ := vc.FindTicks(TestPID)
+	AssertEquals(t, uint64(1), n, "UnpackReceiveE: Clock value incremented on failure")
+	AssertEquals(t, 1, len(vc), "UnpackReceiveE: Clock merged on failure")
+}
+
+func TestUnpackReceiveMissingPid(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+
+	packed, err := defaultEncoder(&VClockPayload{Pid: "Other", VcMap: map[string]uint64{"Third": 4}, Payload: 1337})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := 42
+	err = gv.UnpackReceiveE("TestMessage1", packed, &response, opts)
+
+	AssertTrue(t, errors.Is(err, ErrMissingPid), "UnpackReceiveE: expected a missing pid error")
+	AssertEquals(t, 42, response, "UnpackReceiveE: payload unpacked on failure")
+
+	vc := gv.GetCurrentVC()
+	_, found := vc.FindTicks("Third")
+	AssertTrue(t, !found, "UnpackReceiveE: Clock merged on failure")
+}
+
+func TestConcurrentUse(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	other := InitGoVector("Other", "TestLogFile", GetDefaultConfig())
+	opts := GetDefaultLogOptions()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				gv.LogLocalEvent("TestMessage1", opts)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var response int
+				gv.UnpackReceive("TestMessage2", other.PrepareSend("TestMessage3", j, opts), &response, opts)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				vc := gv.GetCurrentVC()
+				vc.Tick("Intruder")
+				gv.Flush()
+			}
+		}()
+	}
+	wg.Wait()
+
+	vc := gv.GetCurrentVC()
+	n, _ := vc.FindTicks(TestPID)
+	AssertEquals(t, uint64(401), n, "Concurrent use: Clock value increments lost")
+	_, found := vc.FindTicks("Intruder")
+	AssertTrue(t, !found, "GetCurrentVC: returned clock shares memory with the log")
+}
+
 func BenchmarkPrepare(b *testing.B) {
 
 	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
@@ -0,0 +1,155 @@
+// Package hlc implements Hybrid Logical Clocks, which capture
+// causality like logical clocks while staying close to physical time.
+// Unlike vector clocks their size does not depend on the number of
+// processes: a timestamp is a wall time and a logical counter.
+//
+// See Kulkarni et al., "Logical Physical Clocks and Consistent
+// Snapshots in Globally Distributed Databases" (2014).
+package hlc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Timestamp is a Hybrid Logical Clock timestamp. If event e happened
+// before event f then the timestamp of e is before the timestamp of f.
+type Timestamp struct {
+	// Wall is the physical component, in nanoseconds since the Unix
+	// epoch
+	Wall int64
+	// Logical orders events sharing the same physical component
+	Logical uint32
+}
+
+// ErrMalformedBinary is returned when decoding a binary timestamp
+// which is truncated or otherwise invalid.
+var ErrMalformedBinary = errors.New("hlc: malformed binary timestamp")
+
+// Compare returns -1 if t is before other, 1 if t is after other and
+// 0 if they are equal.
+func (t Timestamp) Compare(other Timestamp) int {
+	switch {
+	case t.Wall < other.Wall:
+		return -1
+	case t.Wall > other.Wall:
+		return 1
+	case t.Logical < other.Logical:
+		return -1
+	case t.Logical > other.Logical:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Before reports whether t is before other.
+func (t Timestamp) Before(other Timestamp) bool {
+	return t.Compare(other) < 0
+}
+
+// Time returns the physical component of t as a time.Time.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(0, t.Wall)
+}
+
+// String returns the timestamp formatted as wall.logical
+func (t Timestamp) String() string {
+	return strconv.FormatInt(t.Wall, 10) + "." + strconv.FormatUint(uint64(t.Logical), 10)
+}
+
+// MarshalBinary returns the timestamp encoded as a varint wall time
+// followed by a varint logical counter, at most 15 bytes.
+func (t Timestamp) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen64+binary.MaxVarintLen32)
+	n := binary.PutVarint(buf, t.Wall)
+	n += binary.PutUvarint(buf[n:], uint64(t.Logical))
+	return buf[:n], nil
+}
+
+// UnmarshalBinary decodes a timestamp encoded by MarshalBinary.
+func (t *Timestamp) UnmarshalBinary(data []byte) error {
+	wall, n := binary.Varint(data)
+	if n <= 0 {
+		return ErrMalformedBinary
+	}
+	logical, m := binary.Uvarint(data[n:])
+	if m <= 0 || logical > math.MaxUint32 || n+m != len(data) {
+		return ErrMalformedBinary
+	}
+	t.Wall, t.Logical = wall, uint32(logical)
+	return nil
+}
+
+// Clock is a Hybrid Logical Clock. It is safe for concurrent use.
+type Clock struct {
+	mutex sync.Mutex
+	now   func() time.Time
+	last  Timestamp
+}
+
+// New returns a Clock reading physical time from now, or time.Now if
+// now is nil.
+func New(now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+	return &Clock{now: now}
+}
+
+// Now advances the clock for a local or send event and returns the
+// timestamp of the event.
+func (c *Clock) Now() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if pt := c.now().UnixNano(); pt > c.last.Wall {
+		c.last = Timestamp{Wall: pt}
+	} else {
+		c.last = next(c.last.Wall, c.last.Logical)
+	}
+	return c.last
+}
+
+// Update advances the clock for the receipt of a message stamped with
+// remote and returns the timestamp of the receive event, which is
+// after both remote and every timestamp previously returned.
+func (c *Clock) Update(remote Timestamp) Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pt := c.now().UnixNano()
+	switch {
+	case pt > c.last.Wall && pt > remote.Wall:
+		c.last = Timestamp{Wall: pt}
+	case c.last.Wall == remote.Wall:
+		logical := c.last.Logical
+		if remote.Logical > logical {
+			logical = remote.Logical
+		}
+		c.last = next(c.last.Wall, logical)
+	case c.last.Wall > remote.Wall:
+		c.last = next(c.last.Wall, c.last.Logical)
+	default:
+		c.last = next(remote.Wall, remote.Logical)
+	}
+	return c.last
+}
+
+// Last returns the timestamp of the latest event.
+func (c *Clock) Last() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.last
+}
+
+// next returns the timestamp following wall.logical, moving to the
+// next nanosecond if the logical counter would overflow.
+func next(wall int64, logical uint32) Timestamp {
+	if logical == math.MaxUint32 {
+		return Timestamp{Wall: wall + 1}
+	}
+	return Timestamp{Wall: wall, Logical: logical + 1}
+}
@@ -0,0 +1,92 @@
+package hlc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// manualTime is a physical clock which only moves when told to
+type manualTime struct {
+	ns int64
+}
+
+func (m *manualTime) now() time.Time {
+	return time.Unix(0, m.ns)
+}
+
+func TestNowFollowsPhysicalTime(t *testing.T) {
+	pt := &manualTime{ns: 100}
+	c := New(pt.now)
+
+	AssertEquals(t, Timestamp{Wall: 100}, c.Now(), "Now: wrong timestamp")
+	AssertEquals(t, Timestamp{Wall: 100, Logical: 1}, c.Now(), "Now: logical counter not incremented")
+
+	pt.ns = 200
+	AssertEquals(t, Timestamp{Wall: 200}, c.Now(), "Now: physical time not adopted")
+
+	pt.ns = 150
+	AssertEquals(t, Timestamp{Wall: 200, Logical: 1}, c.Now(), "Now: clock went backwards")
+}
+
+func TestUpdate(t *testing.T) {
+	pt := &manualTime{ns: 100}
+	c := New(pt.now)
+	c.Now()
+
+	// A remote clock ahead of physical time is adopted
+	AssertEquals(t, Timestamp{Wall: 500, Logical: 4}, c.Update(Timestamp{Wall: 500, Logical: 3}), "Update: remote not adopted")
+	// Equal walls take the highest logical counter
+	AssertEquals(t, Timestamp{Wall: 500, Logical: 8}, c.Update(Timestamp{Wall: 500, Logical: 7}), "Update: wrong logical counter")
+	// A remote clock behind the local clock only ticks it
+	AssertEquals(t, Timestamp{Wall: 500, Logical: 9}, c.Update(Timestamp{Wall: 300, Logical: 20}), "Update: local clock not kept")
+	// Physical time ahead of both resets the logical counter
+	pt.ns = 900
+	AssertEquals(t, Timestamp{Wall: 900}, c.Update(Timestamp{Wall: 600}), "Update: physical time not adopted")
+}
+
+func TestCausality(t *testing.T) {
+	// The sender's physical clock is far ahead of the receiver's
+	sender := New((&manualTime{ns: 1000}).now)
+	receiver := New((&manualTime{ns: 10}).now)
+
+	sent := sender.Now()
+	received := receiver.Update(sent)
+	local := receiver.Now()
+
+	if !sent.Before(received) || !received.Before(local) {
+		t.Fatalf("Causality violated: %s, %s, %s", sent, received, local)
+	}
+}
+
+func TestLogicalOverflow(t *testing.T) {
+	c := New((&manualTime{ns: 100}).now)
+	c.Update(Timestamp{Wall: 100, Logical: math.MaxUint32 - 1})
+
+	AssertEquals(t, Timestamp{Wall: 101}, c.Now(), "Now: logical overflow not carried")
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, ts := range []Timestamp{{}, {Wall: time.Now().UnixNano(), Logical: 42}, {Wall: -1, Logical: math.MaxUint32}} {
+		data, _ := ts.MarshalBinary()
+		var decoded Timestamp
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		AssertEquals(t, ts, decoded, "Binary: timestamp does not round trip")
+	}
+
+	data, _ := Timestamp{Wall: 1, Logical: 1}.MarshalBinary()
+	var decoded Timestamp
+	for _, malformed := range [][]byte{nil, data[:1], append(data, 0)} {
+		if decoded.UnmarshalBinary(malformed) == nil {
+			t.Fatalf("Binary: malformed timestamp %x decoded", malformed)
+		}
+	}
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+" Expected: %v, Actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,152 @@
+//Package httpvec provides support for automatically logging HTTP
+//requests and responses between a net/http client and server. The
+//vector clock travels in the VClock header of requests and responses,
+//leaving their bodies untouched.
+package httpvec
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+//HeaderName is the HTTP header carrying the encoded vector clock.
+const HeaderName = "VClock"
+
+//Transport is an http.RoundTripper which uses a logger of type GoLog
+//to capture every request sent to a server as well as the responses
+//of the server.
+type Transport struct {
+	Base    http.RoundTripper
+	Logger  *govec.GoLog
+	Options govec.GoLogOptions
+}
+
+//NewTransport returns a Transport which sends requests through base,
+//or http.DefaultTransport if base is nil.
+func NewTransport(base http.RoundTripper, logger *govec.GoLog, options govec.GoLogOptions) *Transport {
+	return &Transport{Base: base, Logger: logger, Options: options}
+}
+
+//RoundTrip ticks the vector clock, adds it to a copy of req and sends
+//it. If the response carries a vector clock it is merged into the
+//local clock; a malformed clock fails the round trip.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	buf, err := t.Logger.PrepareSendCtx(req.Context(), "Sending HTTP request "+req.Method+" "+req.URL.String(), nil, t.Options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		//RoundTrip must close the body, even on errors
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	if buf != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderName, base64.StdEncoding.EncodeToString(buf))
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := resp.Header.Get(HeaderName)
+	if header == "" {
+		return resp, nil
+	}
+	_, err = receive(req.Context(), t.Logger, "Received HTTP response "+resp.Status, header, t.Options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+//receive decodes a vector clock header and merges it into the clock of
+//logger. The returned context carries the merged clock.
+func receive(ctx context.Context, logger *govec.GoLog, mesg string, header string, options govec.GoLogOptions) (context.Context, error) {
+	buf, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return ctx, &govec.Error{Kind: govec.ErrDecode, Err: err}
+	}
+	return logger.UnpackReceiveCtx(ctx, mesg, buf, nil, options)
+}
+
+//Handler returns an http.Handler which merges the vector clock of
+//every incoming request into the logger's clock before calling next,
+//and stamps the response with the logger's clock. The context of the
+//request passed to next carries the clock, see govec.ClockFromContext.
+//Requests with a malformed clock are rejected with 400 Bad Request;
+//requests without a clock are served without logging a receive event.
+func Handler(logger *govec.GoLog, next http.Handler, options govec.GoLogOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get(HeaderName); header != "" {
+			ctx, err := receive(r.Context(), logger, "Received HTTP request "+r.Method+" "+r.URL.String(), header, options)
+			if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+				http.Error(w, "malformed "+HeaderName+" header", http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(ctx)
+		}
+
+		sw := &stampingWriter{ResponseWriter: w, request: r, logger: logger, options: options}
+		next.ServeHTTP(sw, r)
+		sw.stamp()
+	})
+}
+
+//stampingWriter adds the vector clock to the headers of a response
+//right before they are written.
+type stampingWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	logger  *govec.GoLog
+	options govec.GoLogOptions
+	stamped bool
+}
+
+func (w *stampingWriter) stamp() {
+	if w.stamped {
+		return
+	}
+	w.stamped = true
+	buf, _ := w.logger.PrepareSendCtx(w.request.Context(), "Sending HTTP response", nil, w.options)
+	if buf != nil {
+		w.Header().Set(HeaderName, base64.StdEncoding.EncodeToString(buf))
+	}
+}
+
+//WriteHeader stamps the response and sends its headers.
+func (w *stampingWriter) WriteHeader(statusCode int) {
+	w.stamp()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+//Write stamps the response if its headers have not been sent yet and
+//writes b to the body.
+func (w *stampingWriter) Write(b []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(b)
+}
+
+//Flush sends any buffered data to the client, if supported by the
+//underlying http.ResponseWriter.
+func (w *stampingWriter) Flush() {
+	w.stamp()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Unwrap returns the underlying http.ResponseWriter.
+func (w *stampingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,98 @@
+package httpvec
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+)
+
+func TestRoundTrip(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+
+	var handlerClientTicks uint64
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vc, _ := govec.ClockFromContext(r.Context())
+		handlerClientTicks = vc["client"]
+		w.Write([]byte("hello"))
+	})
+	server := httptest.NewServer(Handler(serverlogger, mux, options))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, clientlogger, options)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	server_vc := serverlogger.GetCurrentVC()
+	server_ticks, _ := server_vc.FindTicks("server")
+	client_vc := clientlogger.GetCurrentVC()
+	client_ticks, _ := client_vc.FindTicks("client")
+	merged_ticks, _ := client_vc.FindTicks("server")
+
+	AssertEquals(t, "hello", string(body), "Response body altered")
+	AssertEquals(t, uint64(2), handlerClientTicks, "Request clock not in handler context")
+	AssertEquals(t, uint64(3), server_ticks, "Server Clock value not incremented")
+	AssertEquals(t, uint64(3), client_ticks, "Client Clock value not incremented")
+	AssertEquals(t, uint64(3), merged_ticks, "Server clock not merged into client clock")
+}
+
+func TestMalformedHeader(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+
+	handler := Handler(serverlogger, http.NotFoundHandler(), options)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderName, "bm90IGEgY2xvY2s=")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	server_ticks, _ := serverlogger.GetCurrentVC().FindTicks("server")
+	AssertEquals(t, http.StatusBadRequest, rec.Code, "Malformed clock not rejected")
+	AssertEquals(t, uint64(1), server_ticks, "Server Clock value incremented")
+	AssertEquals(t, true, strings.Contains(rec.Body.String(), HeaderName), "Wrong error message")
+}
+
+//closeRecorder is a request body recording whether it was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRoundTripEncodeError(t *testing.T) {
+	config := govec.GetDefaultConfig()
+	config.EncodingStrategy = func(interface{}) ([]byte, error) {
+		return nil, errors.New("cannot encode")
+	}
+	config.DecodingStrategy = func([]byte, interface{}) error { return nil }
+	clientlogger := govec.InitGoVector("client", "clientlogfile", config)
+	options := govec.GetDefaultLogOptions()
+
+	body := &closeRecorder{Reader: strings.NewReader("hello")}
+	req := httptest.NewRequest("POST", "http://localhost/", body)
+	_, err := NewTransport(nil, clientlogger, options).RoundTrip(req)
+
+	AssertEquals(t, true, errors.Is(err, govec.ErrEncode), "Encode error not returned")
+	AssertEquals(t, true, body.closed, "Request body not closed")
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+"Expected: %v, Actual: %v", expected, actual)
+	}
+}
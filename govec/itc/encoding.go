@@ -0,0 +1,295 @@
+package itc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// binaryVersion is the version of the binary encoding written by
+// MarshalBinary
+const binaryVersion = 1
+
+// maxDepth bounds the depth of decoded trees
+const maxDepth = 512
+
+// ErrMalformedBinary is returned when decoding a binary stamp which is
+// truncated or otherwise invalid.
+var ErrMalformedBinary = errors.New("itc: malformed binary stamp")
+
+// String returns the stamp in the notation of the ITC paper, such as
+// ((1, 0), (1, 2, 0)): the identity, where a node is written (l, r),
+// followed by the event tree, where a node is written (n, l, r).
+func (s Stamp) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	writeID(&b, s.ident())
+	b.WriteString(", ")
+	writeEvent(&b, s.events())
+	b.WriteByte(')')
+	return b.String()
+}
+
+func writeID(b *strings.Builder, i *id) {
+	switch {
+	case i.isZero():
+		b.WriteByte('0')
+	case i.isOne():
+		b.WriteByte('1')
+	default:
+		b.WriteByte('(')
+		writeID(b, i.l)
+		b.WriteString(", ")
+		writeID(b, i.r)
+		b.WriteByte(')')
+	}
+}
+
+func writeEvent(b *strings.Builder, e *event) {
+	if e.isLeaf() {
+		b.WriteString(strconv.FormatUint(e.n, 10))
+		return
+	}
+	b.WriteByte('(')
+	b.WriteString(strconv.FormatUint(e.n, 10))
+	b.WriteString(", ")
+	writeEvent(b, e.l)
+	b.WriteString(", ")
+	writeEvent(b, e.r)
+	b.WriteByte(')')
+}
+
+// Parse parses a stamp written by String. The parsed trees are
+// normalized.
+func Parse(s string) (Stamp, error) {
+	p := parser{input: s}
+	p.expect('(')
+	i := p.parseID(0)
+	p.expect(',')
+	e := p.parseEvent(0)
+	p.expect(')')
+	p.skipSpace()
+	if p.err == nil && p.pos != len(s) {
+		p.fail("unexpected data after stamp")
+	}
+	if p.err != nil {
+		return Stamp{}, p.err
+	}
+	return Stamp{id: i, ev: e}, nil
+}
+
+// parser holds the state of Parse, recording the first error
+// encountered.
+type parser struct {
+	input string
+	pos   int
+	err   error
+}
+
+func (p *parser) fail(msg string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("itc: invalid stamp %q at offset %d: %s", p.input, p.pos, msg)
+	}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek returns the next byte after any space, or 0 at the end of the
+// input
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.err != nil || p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) expect(c byte) {
+	if p.peek() != c {
+		p.fail(fmt.Sprintf("expected %q", c))
+		return
+	}
+	p.pos++
+}
+
+func (p *parser) parseID(depth int) *id {
+	if depth > maxDepth {
+		p.fail("identity too deep")
+		return idZero
+	}
+	switch p.peek() {
+	case '0':
+		p.pos++
+		return idZero
+	case '1':
+		p.pos++
+		return idOne
+	case '(':
+		p.pos++
+		l := p.parseID(depth + 1)
+		p.expect(',')
+		r := p.parseID(depth + 1)
+		p.expect(')')
+		return newID(l, r)
+	default:
+		p.fail("expected an identity")
+		return idZero
+	}
+}
+
+func (p *parser) parseEvent(depth int) *event {
+	if depth > maxDepth {
+		p.fail("event tree too deep")
+		return leaf(0)
+	}
+	if p.peek() != '(' {
+		return leaf(p.parseUint())
+	}
+	p.pos++
+	n := p.parseUint()
+	p.expect(',')
+	l := p.parseEvent(depth + 1)
+	p.expect(',')
+	r := p.parseEvent(depth + 1)
+	p.expect(')')
+	return newEvent(n, l, r)
+}
+
+func (p *parser) parseUint() uint64 {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.ParseUint(p.input[start:p.pos], 10, 64)
+	if err != nil {
+		p.pos = start
+		p.fail("expected an event count")
+	}
+	return n
+}
+
+// MarshalBinary returns the compact binary encoding of the stamp: a
+// version byte, the identity in preorder with one byte per node, and
+// the event tree in preorder with one varint per node holding its
+// count and whether it is a leaf.
+func (s Stamp) MarshalBinary() ([]byte, error) {
+	buf := []byte{binaryVersion}
+	buf = appendID(buf, s.ident())
+	return appendEvent(buf, s.events()), nil
+}
+
+func appendID(buf []byte, i *id) []byte {
+	switch {
+	case i.isZero():
+		return append(buf, 0)
+	case i.isOne():
+		return append(buf, 1)
+	default:
+		buf = append(buf, 2)
+		buf = appendID(buf, i.l)
+		return appendID(buf, i.r)
+	}
+}
+
+func appendEvent(buf []byte, e *event) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	if e.isLeaf() {
+		n := binary.PutUvarint(tmp[:], e.n<<1)
+		return append(buf, tmp[:n]...)
+	}
+	n := binary.PutUvarint(tmp[:], e.n<<1|1)
+	buf = append(buf, tmp[:n]...)
+	buf = appendEvent(buf, e.l)
+	return appendEvent(buf, e.r)
+}
+
+// UnmarshalBinary decodes a stamp encoded by MarshalBinary, replacing
+// the callee.
+func (s *Stamp) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return ErrMalformedBinary
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("itc: unsupported binary stamp version %d", data[0])
+	}
+	d := decoder{data: data[1:]}
+	i := d.readID(0)
+	e := d.readEvent(0)
+	if d.err || len(d.data) != 0 {
+		return ErrMalformedBinary
+	}
+	*s = Stamp{id: i, ev: e}
+	return nil
+}
+
+// decoder holds the state of UnmarshalBinary, recording whether the
+// input was malformed.
+type decoder struct {
+	data []byte
+	err  bool
+}
+
+func (d *decoder) readID(depth int) *id {
+	if d.err || len(d.data) == 0 || depth > maxDepth {
+		d.err = true
+		return idZero
+	}
+	tag := d.data[0]
+	d.data = d.data[1:]
+	switch tag {
+	case 0:
+		return idZero
+	case 1:
+		return idOne
+	case 2:
+		l := d.readID(depth + 1)
+		r := d.readID(depth + 1)
+		return newID(l, r)
+	default:
+		d.err = true
+		return idZero
+	}
+}
+
+func (d *decoder) readEvent(depth int) *event {
+	if d.err || depth > maxDepth {
+		d.err = true
+		return leaf(0)
+	}
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = true
+		return leaf(0)
+	}
+	d.data = d.data[n:]
+	if x&1 == 0 {
+		return leaf(x >> 1)
+	}
+	l := d.readEvent(depth + 1)
+	r := d.readEvent(depth + 1)
+	return newEvent(x>>1, l, r)
+}
+
+// MarshalText implements encoding.TextMarshaler, returning the stamp
+// as written by String.
+func (s Stamp) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing text with
+// Parse and replacing the callee.
+func (s *Stamp) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
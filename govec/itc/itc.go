@@ -0,0 +1,357 @@
+// Package itc implements Interval Tree Clocks, a causality tracking
+// mechanism for systems whose processes come and go. Instead of one
+// entry per process ever seen, an ITC stamp holds an identity, a
+// share of the interval [0, 1) owned by the process, and an event tree
+// recording the events seen over that interval. Processes are created
+// by forking the stamp of an existing process and retired by joining
+// their stamp into another, so the size of stamps follows the number
+// of live processes.
+//
+// See Almeida, Baquero and Fonte, "Interval Tree Clocks: A Logical
+// Clock for Dynamic Systems" (2008).
+package itc
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// ErrOverlap is returned when joining stamps whose identities
+// overlap, which happens when the same identity is joined twice.
+var ErrOverlap = errors.New("itc: joining stamps with overlapping identities")
+
+// id is an identity tree. A leaf owns the whole of its interval if one
+// is set and none of it otherwise, a node splits its interval in two
+// halves. Trees are normalized and never modified once built.
+type id struct {
+	one  bool
+	l, r *id
+}
+
+var (
+	idZero = &id{}
+	idOne  = &id{one: true}
+)
+
+func (i *id) isLeaf() bool { return i.l == nil }
+func (i *id) isZero() bool { return i.isLeaf() && !i.one }
+func (i *id) isOne() bool  { return i.isLeaf() && i.one }
+
+// newID returns the normalized node with halves l and r
+func newID(l, r *id) *id {
+	if l.isLeaf() && r.isLeaf() && l.one == r.one {
+		return l
+	}
+	return &id{l: l, r: r}
+}
+
+// split returns two disjoint identities whose sum is i
+func split(i *id) (*id, *id) {
+	switch {
+	case i.isZero():
+		return idZero, idZero
+	case i.isOne():
+		return newID(idOne, idZero), newID(idZero, idOne)
+	case i.l.isZero():
+		a, b := split(i.r)
+		return newID(idZero, a), newID(idZero, b)
+	case i.r.isZero():
+		a, b := split(i.l)
+		return newID(a, idZero), newID(b, idZero)
+	default:
+		return newID(i.l, idZero), newID(idZero, i.r)
+	}
+}
+
+// sum returns the union of the disjoint identities a and b
+func sum(a, b *id) (*id, error) {
+	switch {
+	case a.isZero():
+		return b, nil
+	case b.isZero():
+		return a, nil
+	case a.isLeaf() || b.isLeaf():
+		return nil, ErrOverlap
+	}
+	l, err := sum(a.l, b.l)
+	if err != nil {
+		return nil, err
+	}
+	r, err := sum(a.r, b.r)
+	if err != nil {
+		return nil, err
+	}
+	return newID(l, r), nil
+}
+
+// event is an event tree. A leaf records n events over its interval, a
+// node records n events over its interval plus those of its halves.
+// Trees are normalized and never modified once built.
+type event struct {
+	n    uint64
+	l, r *event
+}
+
+func (e *event) isLeaf() bool { return e.l == nil }
+
+func leaf(n uint64) *event {
+	return &event{n: n}
+}
+
+// lift returns e with m more events over its whole interval
+func (e *event) lift(m uint64) *event {
+	if m == 0 {
+		return e
+	}
+	return &event{n: e.n + m, l: e.l, r: e.r}
+}
+
+// newEvent returns the normalized node recording n events plus l and r
+func newEvent(n uint64, l, r *event) *event {
+	if l.isLeaf() && r.isLeaf() && l.n == r.n {
+		return leaf(n + l.n)
+	}
+	// The minimum of a normalized tree is held by its root
+	m := l.n
+	if r.n < m {
+		m = r.n
+	}
+	return &event{n: n + m, l: &event{n: l.n - m, l: l.l, r: l.r}, r: &event{n: r.n - m, l: r.l, r: r.r}}
+}
+
+// maxOf returns the highest number of events recorded over e
+func maxOf(e *event) uint64 {
+	if e.isLeaf() {
+		return e.n
+	}
+	l, r := maxOf(e.l), maxOf(e.r)
+	if r > l {
+		l = r
+	}
+	return e.n + l
+}
+
+func equal(a, b *event) bool {
+	if a.n != b.n || a.isLeaf() != b.isLeaf() {
+		return false
+	}
+	return a.isLeaf() || equal(a.l, b.l) && equal(a.r, b.r)
+}
+
+// join returns the least event tree recording the events of a and b
+func join(a, b *event) *event {
+	if a.isLeaf() && b.isLeaf() {
+		if b.n > a.n {
+			return b
+		}
+		return a
+	}
+	if a.isLeaf() {
+		a = &event{n: a.n, l: leaf(0), r: leaf(0)}
+	}
+	if b.isLeaf() {
+		b = &event{n: b.n, l: leaf(0), r: leaf(0)}
+	}
+	if a.n > b.n {
+		a, b = b, a
+	}
+	d := b.n - a.n
+	return newEvent(a.n, join(a.l, b.l.lift(d)), join(a.r, b.r.lift(d)))
+}
+
+// leq reports whether every event recorded by a is recorded by b
+func leq(a, b *event) bool {
+	if a.n > b.n {
+		return false
+	}
+	switch {
+	case a.isLeaf():
+		return true
+	case b.isLeaf():
+		return leq(a.l.lift(a.n), b) && leq(a.r.lift(a.n), b)
+	default:
+		return leq(a.l.lift(a.n), b.l.lift(b.n)) && leq(a.r.lift(a.n), b.r.lift(b.n))
+	}
+}
+
+// fill raises e over the interval owned by i as far as possible
+// without recording new events
+func fill(i *id, e *event) *event {
+	switch {
+	case i.isZero():
+		return e
+	case i.isOne():
+		return leaf(maxOf(e))
+	case e.isLeaf():
+		return e
+	case i.l.isOne():
+		r := fill(i.r, e.r)
+		l := maxOf(e.l)
+		if r.n > l {
+			l = r.n
+		}
+		return newEvent(e.n, leaf(l), r)
+	case i.r.isOne():
+		l := fill(i.l, e.l)
+		r := maxOf(e.r)
+		if l.n > r {
+			r = l.n
+		}
+		return newEvent(e.n, l, leaf(r))
+	default:
+		return newEvent(e.n, fill(i.l, e.l), fill(i.r, e.r))
+	}
+}
+
+// growPenalty is the cost of expanding a leaf of an event tree, so
+// that grow prefers incrementing existing leaves
+const growPenalty = 1 << 20
+
+// grow records a new event over the interval owned by i, picking the
+// change which keeps the tree smallest, and returns its cost
+func grow(i *id, e *event) (*event, int) {
+	if i.isOne() {
+		return leaf(maxOf(e) + 1), 0
+	}
+	if e.isLeaf() {
+		g, cost := grow(i, &event{n: e.n, l: leaf(0), r: leaf(0)})
+		return g, cost + growPenalty
+	}
+	if i.l.isZero() {
+		r, cost := grow(i.r, e.r)
+		return newEvent(e.n, e.l, r), cost + 1
+	}
+	if i.r.isZero() {
+		l, cost := grow(i.l, e.l)
+		return newEvent(e.n, l, e.r), cost + 1
+	}
+	l, costL := grow(i.l, e.l)
+	r, costR := grow(i.r, e.r)
+	if costL < costR {
+		return newEvent(e.n, l, e.r), costL + 1
+	}
+	return newEvent(e.n, e.l, r), costR + 1
+}
+
+// Stamp is an Interval Tree Clock stamp: the identity of a process
+// and the events it knows of. Stamps are immutable values, every
+// operation returns new stamps. The zero Stamp is anonymous and knows
+// of no events.
+type Stamp struct {
+	id *id
+	ev *event
+}
+
+// Seed returns the stamp of the first process of a system, owning the
+// whole interval. Every other process must be forked from it.
+func Seed() Stamp {
+	return Stamp{id: idOne, ev: leaf(0)}
+}
+
+func (s Stamp) ident() *id {
+	if s.id == nil {
+		return idZero
+	}
+	return s.id
+}
+
+func (s Stamp) events() *event {
+	if s.ev == nil {
+		return leaf(0)
+	}
+	return s.ev
+}
+
+// IsAnonymous reports whether s owns no identity, such as stamps
+// returned by Peek. Anonymous stamps cannot record events.
+func (s Stamp) IsAnonymous() bool {
+	return s.ident().isZero()
+}
+
+// Fork splits the identity of s in two, returning two stamps which
+// know of the events of s. One is kept by the forking process and the
+// other handed to a new process.
+func (s Stamp) Fork() (Stamp, Stamp) {
+	a, b := split(s.ident())
+	return Stamp{id: a, ev: s.ev}, Stamp{id: b, ev: s.ev}
+}
+
+// Join merges s and other into a stamp owning both identities and
+// knowing of the events of both. An error is returned if their
+// identities overlap.
+func (s Stamp) Join(other Stamp) (Stamp, error) {
+	i, err := sum(s.ident(), other.ident())
+	if err != nil {
+		return Stamp{}, err
+	}
+	return Stamp{id: i, ev: join(s.events(), other.events())}, nil
+}
+
+// Event returns s with a new event recorded. Anonymous stamps are
+// returned unchanged.
+func (s Stamp) Event() Stamp {
+	i, e := s.ident(), s.events()
+	if i.isZero() {
+		return s
+	}
+	if f := fill(i, e); !equal(f, e) {
+		return Stamp{id: i, ev: f}
+	}
+	g, _ := grow(i, e)
+	return Stamp{id: i, ev: g}
+}
+
+// Peek returns an anonymous stamp knowing of the events of s, to be
+// sent along with messages and joined by their receivers.
+func (s Stamp) Peek() Stamp {
+	return Stamp{id: idZero, ev: s.ev}
+}
+
+// Leq reports whether every event known by s is known by other, that
+// is whether s happened before or is equal to other.
+func (s Stamp) Leq(other Stamp) bool {
+	return leq(s.events(), other.events())
+}
+
+// Record is an event of process Pid stamped with Stamp.
+type Record struct {
+	Pid   string
+	Stamp Stamp
+}
+
+// VClocks converts a trace of ITC stamped events into vector clocks,
+// which ShiViz can render. The clock of an event counts, for every
+// process, how many of its events happened before or at that event.
+// The records of every process must appear in the order they happened
+// and each must follow a call to Event, so that the stamps of a
+// process strictly increase.
+func VClocks(records []Record) []vclock.VClock {
+	var pids []string
+	byPid := make(map[string][]Stamp)
+	for _, r := range records {
+		if _, found := byPid[r.Pid]; !found {
+			pids = append(pids, r.Pid)
+		}
+		byPid[r.Pid] = append(byPid[r.Pid], r.Stamp)
+	}
+
+	vcs := make([]vclock.VClock, len(records))
+	for i, r := range records {
+		vc := vclock.New()
+		for _, pid := range pids {
+			// The stamps of a process increase, so those known by r
+			// form a prefix
+			stamps := byPid[pid]
+			n := sort.Search(len(stamps), func(k int) bool {
+				return !stamps[k].Leq(r.Stamp)
+			})
+			if n > 0 {
+				vc.Set(pid, uint64(n))
+			}
+		}
+		vcs[i] = vc
+	}
+	return vcs
+}
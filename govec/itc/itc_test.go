@@ -0,0 +1,198 @@
+package itc
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestForkEventJoin(t *testing.T) {
+
+	a, b := Seed().Fork()
+	a = a.Event()
+	b = b.Event()
+	a, c := a.Fork()
+	b = b.Event()
+	a = a.Event()
+	b, err := b.Join(c)
+	AssertTrue(t, err == nil, "Join: unexpected error")
+	b, c = b.Fork()
+	a, err = a.Join(b)
+	AssertTrue(t, err == nil, "Join: unexpected error")
+	a = a.Event()
+
+	AssertEquals(t, "((1, 0), 2)", a.String(), "Stamp: wrong stamp a")
+	AssertEquals(t, "((0, 1), (1, 0, 1))", c.String(), "Stamp: wrong stamp c")
+	AssertTrue(t, c.Leq(a), "Leq: c should happen before a")
+	AssertTrue(t, !a.Leq(c), "Leq: a should not happen before c")
+}
+
+func TestJoinOverlap(t *testing.T) {
+
+	a, _ := Seed().Fork()
+	if _, err := a.Join(a); err != ErrOverlap {
+		t.Fatalf("Join: expected ErrOverlap, got %v", err)
+	}
+}
+
+func TestAnonymousEvent(t *testing.T) {
+
+	var s Stamp
+	AssertTrue(t, s.IsAnonymous(), "IsAnonymous: zero stamp is not anonymous")
+	AssertEquals(t, "(0, 0)", s.Event().String(), "Event: anonymous stamp changed")
+	AssertTrue(t, Seed().Event().Peek().IsAnonymous(), "Peek: stamp is not anonymous")
+}
+
+// traced is an event of a simulated run along with the events which
+// happened before or at it
+type traced struct {
+	record  Record
+	history map[int]bool
+}
+
+type process struct {
+	pid     string
+	stamp   Stamp
+	history map[int]bool
+}
+
+func copyHistory(h map[int]bool) map[int]bool {
+	cp := make(map[int]bool, len(h))
+	for k := range h {
+		cp[k] = true
+	}
+	return cp
+}
+
+// simulate runs processes which record events, exchange messages, fork
+// new processes and retire, tracking causality by hand.
+func simulate(t *testing.T, r *rand.Rand, steps int) []traced {
+	var trace []traced
+	procs := []*process{{pid: "p0", stamp: Seed(), history: map[int]bool{}}}
+	forks := 0
+
+	record := func(p *process) {
+		p.stamp = p.stamp.Event()
+		p.history[len(trace)] = true
+		trace = append(trace, traced{Record{p.pid, p.stamp}, copyHistory(p.history)})
+	}
+
+	for i := 0; i < steps; i++ {
+		p := procs[r.Intn(len(procs))]
+		q := procs[r.Intn(len(procs))]
+		switch op := r.Intn(10); {
+		case op < 4:
+			record(p)
+		case op < 7 && p != q:
+			record(p)
+			msg, err := q.stamp.Join(p.stamp.Peek())
+			AssertTrue(t, err == nil, "Join: unexpected error")
+			q.stamp = msg
+			for k := range p.history {
+				q.history[k] = true
+			}
+			record(q)
+		case op < 9 && len(procs) < 8:
+			forks++
+			var child Stamp
+			p.stamp, child = p.stamp.Fork()
+			procs = append(procs, &process{pid: "p" + strconv.Itoa(forks), stamp: child, history: copyHistory(p.history)})
+		case p != q:
+			// p retires into q
+			joined, err := q.stamp.Join(p.stamp)
+			AssertTrue(t, err == nil, "Join: unexpected error")
+			q.stamp = joined
+			for k := range p.history {
+				q.history[k] = true
+			}
+			for k := range procs {
+				if procs[k] == p {
+					procs = append(procs[:k], procs[k+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return trace
+}
+
+func TestLeqMatchesCausality(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 20; run++ {
+		trace := simulate(t, r, 200)
+		for _, a := range trace {
+			for j, b := range trace {
+				if b.record.Stamp.Leq(a.record.Stamp) != a.history[j] {
+					t.Fatalf("Leq: %s <= %s should be %v", b.record.Stamp, a.record.Stamp, a.history[j])
+				}
+			}
+		}
+	}
+}
+
+func TestVClocks(t *testing.T) {
+
+	r := rand.New(rand.NewSource(2))
+	for run := 0; run < 20; run++ {
+		trace := simulate(t, r, 200)
+		records := make([]Record, len(trace))
+		for i := range trace {
+			records[i] = trace[i].record
+		}
+		vcs := VClocks(records)
+
+		for i, a := range trace {
+			// Clocks count the events of every process known to a
+			expected := vclock.New()
+			for k := range a.history {
+				expected.Tick(trace[k].record.Pid)
+			}
+			AssertEquals(t, expected.ReturnVCString(), vcs[i].ReturnVCString(), "VClocks: wrong clock")
+			for j := range trace {
+				AssertEquals(t, trace[j].history[i] && i != j, vclock.HappenedBefore(vcs[i], vcs[j]), "VClocks: wrong order")
+			}
+		}
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+
+	r := rand.New(rand.NewSource(3))
+	for _, tr := range simulate(t, r, 200) {
+		s := tr.record.Stamp
+
+		data, _ := s.MarshalBinary()
+		var decoded Stamp
+		AssertTrue(t, decoded.UnmarshalBinary(data) == nil, "Binary: decoding failed")
+		AssertEquals(t, s.String(), decoded.String(), "Binary: stamp does not round trip")
+
+		parsed, err := Parse(s.String())
+		AssertTrue(t, err == nil, "Parse: parsing failed")
+		AssertEquals(t, s.String(), parsed.String(), "Parse: stamp does not round trip")
+	}
+
+	data, _ := Seed().Event().MarshalBinary()
+	var decoded Stamp
+	for _, malformed := range [][]byte{nil, data[:2], append(data, 0), {binaryVersion, 3, 0}} {
+		AssertTrue(t, decoded.UnmarshalBinary(malformed) != nil, "Binary: malformed stamp decoded")
+	}
+	for _, malformed := range []string{"", "(1, 0", "(2, 0)", "((1, 0), (1, 2))", "(1, 0) x"} {
+		_, err := Parse(malformed)
+		AssertTrue(t, err != nil, "Parse: malformed stamp "+malformed+" parsed")
+	}
+}
+
+func AssertTrue(t *testing.T, condition bool, message string) {
+	if !condition {
+		t.Fatalf(message)
+	}
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+" Expected: %v, Actual: %v", expected, actual)
+	}
+}
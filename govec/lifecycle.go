@@ -0,0 +1,106 @@
+package govec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Close logs a final event, carrying the last clock of the process, and
+// closes the GoLog. See CloseContext.
+func (gv *GoLog) Close() error {
+	return gv.CloseContext(context.Background())
+}
+
+// CloseContext logs a final event, carrying the last clock of the
+// process, writes the buffered log entries, waits for the background
+// writer of an asynchronous GoLog to write every queued entry, and
+// closes the sinks. If ctx is done first, its error is returned and the
+// remaining entries are still written in the background. Events logged
+// afterwards are not written anywhere. Closing a closed GoLog does
+// nothing. Every failure is reported: an error of kind ErrClockSave if
+// the clock could not be saved to the ClockStore, of kind ErrLogWrite
+// if entries could not be written, and the errors of closing the
+// sinks.
+func (gv *GoLog) CloseContext(ctx context.Context) error {
+	return gv.closeWith(ctx, "Process terminated")
+}
+
+// closeWith implements CloseContext, logging mesg as the final event
+func (gv *GoLog) closeWith(ctx context.Context, mesg string) error {
+	gv.mutex.Lock()
+	if gv.closed {
+		gv.mutex.Unlock()
+		return nil
+	}
+	if gv.signals != nil {
+		signal.Stop(gv.signals)
+		close(gv.stopSignals)
+	}
+
+	var errs []error
+	if gv.logging {
+		// The clock is saved below rather than by a checkpoint
+		gv.advanceClock()
+		gv.unsaved++
+		gv.logThis(gv.newSystemEvent(mesg, true))
+	}
+	if gv.store != nil && gv.unsaved > 0 {
+		errs = append(errs, gv.saveClock())
+	}
+	if !gv.flush() {
+		errs = append(errs, newError(ErrLogWrite, nil))
+	}
+	gv.closed = true
+	w := gv.async
+	if w != nil {
+		w.close()
+	} else {
+		for _, sink := range gv.sinks {
+			errs = append(errs, sink.Close())
+		}
+	}
+	gv.mutex.Unlock()
+
+	if w != nil {
+		if werr := w.wait(ctx); werr != nil {
+			return joinErrors(append(errs, werr)...)
+		}
+		if atomic.LoadInt32(&w.failed) != 0 && !errors.Is(joinErrors(errs...), ErrLogWrite) {
+			errs = append(errs, newError(ErrLogWrite, nil))
+		}
+	}
+	return joinErrors(errs...)
+}
+
+// closeOnSignal closes the GoLog upon SIGINT or SIGTERM, then raises
+// the signal again so that the process terminates as it would have
+// without the GoLog, unless the application handles the signal too.
+// Where the signal cannot be raised, as os.Interrupt on Windows, the
+// process exits with status 130 for SIGINT and 1 otherwise.
+func (gv *GoLog) closeOnSignal() {
+	gv.signals = make(chan os.Signal, 1)
+	gv.stopSignals = make(chan struct{})
+	signal.Notify(gv.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func(signals chan os.Signal, stop chan struct{}) {
+		select {
+		case sig := <-signals:
+			gv.closeWith(context.Background(), "Process terminated by signal "+sig.String())
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(sig)
+			}
+			if err != nil {
+				if sig == os.Interrupt {
+					os.Exit(130)
+				}
+				os.Exit(1)
+			}
+		case <-stop:
+		}
+	}(gv.signals, gv.stopSignals)
+}
@@ -0,0 +1,38 @@
+package govec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Buffered = true
+	config.Sinks = []Sink{ring}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+	AssertEquals(t, 0, len(ring.Entries()), "Close: entries not buffered")
+
+	AssertTrue(t, gv.Close() == nil, "Close: unexpected error")
+	entries := ring.Entries()
+	AssertEquals(t, 3, len(entries), "Close: buffered entries not written")
+	AssertEquals(t, "TestPID {\"TestPID\":3}\nProcess terminated\n", entries[2], "Close: wrong final event")
+
+	AssertTrue(t, gv.Close() == nil, "Close: second call failed")
+	AssertTrue(t, !gv.LogLocalEvent("TestEvent", GetDefaultLogOptions()), "Close: event logged after Close")
+	gv.Flush()
+	AssertEquals(t, 3, len(ring.Entries()), "Close: entry written after Close")
+}
+
+func TestCloseLogFile(t *testing.T) {
+
+	gv := InitGoVector(TestPID, "TestLogFile", GetDefaultConfig())
+	AssertTrue(t, gv.Close() == nil, "Close: unexpected error")
+	AssertTrue(t, strings.HasSuffix(gv.logfile, "-Log.txt"), "Close: wrong log file")
+	file := gv.sinks[0].(*FileSink)
+	_, err := file.Write([]byte("entry\n"))
+	AssertTrue(t, err != nil, "Close: log file not closed")
+}
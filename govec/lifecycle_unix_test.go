@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package govec
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFlushOnSignal(t *testing.T) {
+
+	// Catch the signal raised again once the GoLog is closed, which
+	// would otherwise terminate the test
+	caught := make(chan os.Signal, 2)
+	signal.Notify(caught, syscall.SIGTERM)
+	defer signal.Stop(caught)
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Buffered = true
+	config.FlushOnSignal = true
+	config.Sinks = []Sink{ring}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+
+	AssertTrue(t, syscall.Kill(os.Getpid(), syscall.SIGTERM) == nil, "FlushOnSignal: could not raise signal")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-caught:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("FlushOnSignal: signal not raised again")
+		}
+	}
+
+	entries := ring.Entries()
+	AssertEquals(t, 3, len(entries), "FlushOnSignal: buffered entries not written")
+	AssertTrue(t, strings.HasSuffix(entries[2], "\nProcess terminated by signal terminated\n"), "FlushOnSignal: wrong final event")
+}
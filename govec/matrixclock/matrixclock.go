@@ -0,0 +1,204 @@
+// Package matrixclock implements matrix clocks, which track what every
+// process knows of the events of every other process. Alongside its
+// own vector clock, a process keeps one vector clock per peer holding
+// what that peer is known to have seen. From them it can tell which
+// events have been seen by everyone, for instance to garbage collect
+// replicated state.
+package matrixclock
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// Clock is a matrix clock: a row per process, each a vector clock.
+// The row of the local process is its own vector clock, and the row of
+// another process holds what it is known to have seen. A Clock is not
+// safe for concurrent use.
+type Clock struct {
+	id   string
+	rows map[string]vclock.VClock
+}
+
+// New returns the matrix clock of process id, which has seen no event.
+func New(id string) *Clock {
+	return &Clock{id: id, rows: map[string]vclock.VClock{id: {id: 0}}}
+}
+
+// ID returns the id of the local process.
+func (c *Clock) ID() string {
+	return c.id
+}
+
+// Local returns a copy of the row of the local process, its vector
+// clock.
+func (c *Clock) Local() vclock.VClock {
+	return c.rows[c.id].Copy()
+}
+
+// Row returns a copy of the row of process id, nil if nothing is known
+// of what id has seen.
+func (c *Clock) Row(id string) vclock.VClock {
+	row, found := c.rows[id]
+	if !found {
+		return nil
+	}
+	return row.Copy()
+}
+
+// Rows returns a copy of every row, to be sent along with a message and
+// passed to Receive by its receiver.
+func (c *Clock) Rows() map[string]vclock.VClock {
+	rows := make(map[string]vclock.VClock, len(c.rows))
+	for id, row := range c.rows {
+		rows[id] = row.Copy()
+	}
+	return rows
+}
+
+// Tick records a local or send event.
+func (c *Clock) Tick() {
+	c.rows[c.id].Tick(c.id)
+}
+
+// Receive records the receipt of a message sent by process from along
+// with its rows. Everything known by the sender becomes known by the
+// local process, which also learns that the sender has seen at least
+// the events in its own row.
+func (c *Clock) Receive(from string, rows map[string]vclock.VClock) {
+	c.Tick()
+	for id, row := range rows {
+		c.merge(id, row)
+	}
+	c.rows[c.id].Merge(rows[from])
+}
+
+func (c *Clock) merge(id string, row vclock.VClock) {
+	if _, found := c.rows[id]; !found {
+		c.rows[id] = vclock.New()
+	}
+	c.rows[id].Merge(row)
+}
+
+// MinKnown returns the vector clock of the events known to have been
+// seen by every process in the local row. An event of process id with
+// clock value n has been seen by everyone if n is lower than or equal
+// to the entry of id. Processes whose row is unknown count as having
+// seen nothing.
+func (c *Clock) MinKnown() vclock.VClock {
+	local := c.rows[c.id]
+	min := local.Copy()
+	for process := range local {
+		row := c.rows[process]
+		for id, ticks := range min {
+			if row[id] < ticks {
+				min[id] = row[id]
+			}
+		}
+	}
+	return min
+}
+
+// KnownByAll reports whether the event of process id with clock value
+// ticks has been seen by every process. See MinKnown.
+func (c *Clock) KnownByAll(id string, ticks uint64) bool {
+	return ticks <= c.MinKnown()[id]
+}
+
+// MatrixPayload is the payload sent by Logger on top of GoLog's
+// VClockPayload: the rows of the sender's matrix clock along with the
+// application's payload.
+type MatrixPayload struct {
+	From    string
+	Rows    map[string]vclock.VClock
+	Payload interface{}
+}
+
+// Logger wraps a GoLog with a matrix clock. The vector clock of the
+// GoLog is the local row of the matrix clock, so the log remains valid
+// ShiViz output, and the other rows travel in the payload of the
+// messages. A Logger is safe for concurrent use, as long as its GoLog
+// is only used through it.
+type Logger struct {
+	gv    *govec.GoLog
+	clock *Clock
+	mutex sync.Mutex
+}
+
+// NewLogger returns a Logger for process processid logging to
+// logfilename. See govec.InitGoVector. config.Clock must be
+// govec.VectorClock.
+func NewLogger(processid string, logfilename string, config govec.GoLogConfig) *Logger {
+	l := &Logger{gv: govec.InitGoVector(processid, logfilename, config), clock: New(processid)}
+	l.syncLocal()
+	return l
+}
+
+// GoLog returns the GoLog of the Logger.
+func (l *Logger) GoLog() *govec.GoLog {
+	return l.gv
+}
+
+// syncLocal copies the vector clock of the GoLog into the local row
+func (l *Logger) syncLocal() {
+	l.clock.rows[l.clock.id] = l.gv.GetCurrentVC()
+}
+
+// LogLocalEvent logs a local event, see GoLog.LogLocalEvent.
+func (l *Logger) LogLocalEvent(mesg string, opts govec.GoLogOptions) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	ok := l.gv.LogLocalEvent(mesg, opts)
+	l.syncLocal()
+	return ok
+}
+
+// PrepareSend encodes buf along with the matrix clock and logs a send
+// event, see GoLog.PrepareSendE. The local row sent along is the one
+// from just before the send event, which receivers thus only learn the
+// sender has seen from later messages.
+func (l *Logger) PrepareSend(mesg string, buf interface{}, opts govec.GoLogOptions) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	payload := &MatrixPayload{From: l.clock.id, Rows: l.clock.Rows(), Payload: buf}
+	encoded, err := l.gv.PrepareSendE(mesg, payload, opts)
+	l.syncLocal()
+	return encoded, err
+}
+
+// UnpackReceive decodes a message encoded by PrepareSend into unpack,
+// merges the matrix clock it carries and logs a receive event, see
+// GoLog.UnpackReceiveE.
+func (l *Logger) UnpackReceive(mesg string, buf []byte, unpack interface{}, opts govec.GoLogOptions) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	payload := &MatrixPayload{Payload: unpack}
+	err := l.gv.UnpackReceiveE(mesg, buf, payload, opts)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return err
+	}
+	// The GoLog already merged the sender's clock into the local row
+	for id, row := range payload.Rows {
+		l.clock.merge(id, row)
+	}
+	l.syncLocal()
+	return err
+}
+
+// MinKnown returns the vector clock of the events known to have been
+// seen by every process, see Clock.MinKnown.
+func (l *Logger) MinKnown() vclock.VClock {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.clock.MinKnown()
+}
+
+// Clock returns a copy of the matrix clock.
+func (l *Logger) Clock() *Clock {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return &Clock{id: l.clock.id, rows: l.clock.Rows()}
+}
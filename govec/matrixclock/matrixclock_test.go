@@ -0,0 +1,92 @@
+package matrixclock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestClockMinKnown(t *testing.T) {
+
+	a, b, c := New("a"), New("b"), New("c")
+
+	// a sends to b and c, who both reply to a
+	a.Tick()
+	sent := a.Rows()
+	b.Receive("a", sent)
+	c.Receive("a", sent)
+	b.Tick()
+	a.Receive("b", b.Rows())
+	c.Tick()
+	a.Receive("c", c.Rows())
+
+	AssertEquals(t, `{"a":3, "b":2, "c":2}`, a.Local().ReturnVCString(), "Receive: wrong local row")
+	AssertEquals(t, `{"a":1, "b":2}`, a.Row("b").ReturnVCString(), "Receive: wrong row of b")
+	// Everyone has seen the send event of a, only b and c their own
+	AssertEquals(t, `{"a":1, "b":0, "c":0}`, a.MinKnown().ReturnVCString(), "MinKnown: wrong clock")
+	AssertTrue(t, a.KnownByAll("a", 1), "KnownByAll: send event not known by all")
+	AssertTrue(t, !a.KnownByAll("a", 2), "KnownByAll: receive event known by all")
+
+	// b knows a has seen its own send event, but nothing of c
+	AssertEquals(t, `{"a":1, "b":0}`, b.MinKnown().ReturnVCString(), "MinKnown: wrong clock")
+	AssertTrue(t, b.Row("c") == nil, "Row: unknown row not nil")
+}
+
+func newLogger(pid string, ring *govec.RingSink) *Logger {
+	config := govec.GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []govec.Sink{ring}
+	return NewLogger(pid, "TestLogFile", config)
+}
+
+func TestLogger(t *testing.T) {
+
+	ringA, ringB := govec.NewRingSink(16), govec.NewRingSink(16)
+	a, b := newLogger("a", ringA), newLogger("b", ringB)
+	opts := govec.GetDefaultLogOptions()
+	var response string
+
+	packed, err := a.PrepareSend("Sending request", "ping", opts)
+	AssertTrue(t, err == nil, "PrepareSend failed")
+	AssertTrue(t, b.UnpackReceive("Receiving request", packed, &response, opts) == nil, "UnpackReceive failed")
+	AssertEquals(t, "ping", response, "UnpackReceive: wrong payload")
+
+	packed, _ = b.PrepareSend("Sending response", "pong", opts)
+	AssertTrue(t, a.UnpackReceive("Receiving response", packed, &response, opts) == nil, "UnpackReceive failed")
+	AssertEquals(t, "pong", response, "UnpackReceive: wrong payload")
+
+	// b has seen the request, and a knows it, along with the events of
+	// b up to the receipt of the request
+	AssertEquals(t, `{"a":2, "b":2}`, a.MinKnown().ReturnVCString(), "MinKnown: wrong clock")
+
+	// The log holds the local row, as ShiViz expects
+	local := a.Clock().Local()
+	AssertEquals(t, local.ReturnVCString(), a.GoLog().GetCurrentVC().ReturnVCString(), "Logger: local row differs from GoLog")
+	entries := ringA.Entries()
+	AssertTrue(t, strings.HasPrefix(entries[len(entries)-1], "a "+local.ReturnVCString()+"\n"), "Logger: log does not hold the local row")
+}
+
+func TestLoggerDecodeError(t *testing.T) {
+
+	a := newLogger("a", govec.NewRingSink(4))
+	before := a.Clock().Local()
+	var response string
+	err := a.UnpackReceive("Receiving garbage", []byte{0xc1}, &response, govec.GetDefaultLogOptions())
+	AssertTrue(t, err != nil, "UnpackReceive: garbage decoded")
+	AssertEquals(t, before.ReturnVCString(), a.Clock().Local().ReturnVCString(), "UnpackReceive: clock changed on error")
+	AssertTrue(t, vclock.Relation(before, a.GoLog().GetCurrentVC()) == vclock.Equal, "UnpackReceive: GoLog clock changed on error")
+}
+
+func AssertTrue(t *testing.T, condition bool, message string) {
+	if !condition {
+		t.Fatalf(message)
+	}
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+" Expected: %v, Actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,108 @@
+package govec
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AppendRecovery decides how a GoLog appending to the log of a previous
+// execution keeps the clocks logged for one host from going backwards,
+// which ShiViz cannot display.
+type AppendRecovery int
+
+const (
+	// RestartClock starts from InitialVC, or zero, whatever the log
+	// holds
+	RestartClock AppendRecovery = iota
+	// ResumeClock carries on from the clock of the last entry of the
+	// process in the log, or starts as RestartClock if there is none.
+	// It is the clock loaded by a LogClockStore
+	ResumeClock
+	// NewIdentity logs under a fresh process id, the id of the process
+	// followed by "#2" for the second execution, "#3" for the third and
+	// so on, starting from InitialVC or zero. Peers see the new id
+	NewIdentity
+)
+
+// recoverFromLog applies the AppendRecovery mode to the log about to be
+// appended to, before anything is logged.
+func (gv *GoLog) recoverFromLog(mode AppendRecovery) {
+	switch mode {
+	case ResumeClock:
+		gv.resumeClock()
+	case NewIdentity:
+		gv.takeNewIdentity()
+	}
+}
+
+// resumeClock implements ResumeClock
+func (gv *GoLog) resumeClock() {
+	vc, err := NewLogClockStore(gv.logfile, gv.pid).Load()
+	if err != nil {
+		gv.logger.Println("Could not recover from the log:", err)
+		return
+	}
+	if vc != nil {
+		gv.currentVC = vc.Copy()
+	}
+}
+
+// takeNewIdentity implements NewIdentity
+func (gv *GoLog) takeNewIdentity() {
+	f, err := os.Open(gv.logfile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		gv.logger.Println("Could not recover from the log:", err)
+		return
+	}
+	defer f.Close()
+
+	pid := gv.pid
+	host, vc, err := lastEntry(f, func(host string) bool {
+		_, ok := executionOf(host, pid)
+		return ok
+	})
+	if err != nil {
+		gv.logger.Println("Could not recover from the log:", err)
+		return
+	}
+	if vc == nil {
+		return
+	}
+
+	execution, _ := executionOf(host, pid)
+	gv.pid = pid + "#" + strconv.Itoa(execution+1)
+	ticks, _ := gv.currentVC.FindTicks(pid)
+	delete(gv.currentVC, pid)
+	gv.currentVC.Set(gv.pid, ticks)
+}
+
+// restoreIdentity takes the identity of the latest execution found in
+// the clock loaded from the ClockStore, see NewIdentity
+func (gv *GoLog) restoreIdentity() {
+	pid, latest := gv.pid, 0
+	for host := range gv.currentVC {
+		if execution, ok := executionOf(host, pid); ok && execution > latest {
+			gv.pid, latest = host, execution
+		}
+	}
+}
+
+// executionOf returns the number of the execution of process pid which
+// logged as host, see NewIdentity
+func executionOf(host string, pid string) (int, bool) {
+	if host == pid {
+		return 1, true
+	}
+	if !strings.HasPrefix(host, pid+"#") {
+		return 0, false
+	}
+	execution, err := strconv.Atoi(host[len(pid)+1:])
+	if err != nil || execution < 2 {
+		return 0, false
+	}
+	return execution, true
+}
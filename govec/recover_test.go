@@ -0,0 +1,116 @@
+package govec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runExecution logs a few events to logfile, appending to the log of
+// the previous executions, and returns the GoLog
+func runExecution(logfile string, recovery AppendRecovery) *GoLog {
+	return runExecutionWith(logfile, recovery, nil)
+}
+
+// runExecutionWith is runExecution with the clock saved to store
+func runExecutionWith(logfile string, recovery AppendRecovery, store ClockStore) *GoLog {
+	config := GetDefaultConfig()
+	config.AppendLog = true
+	config.AppendRecovery = recovery
+	config.ClockStore = store
+	gv := InitGoVector(TestPID, logfile, config)
+	for i := 0; i < 3; i++ {
+		gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+	}
+	gv.Close()
+	return gv
+}
+
+// hostClocks returns the clock value of every entry of the log, per
+// host, in order
+func hostClocks(t *testing.T, filename string) map[string][]uint64 {
+	content, err := ioutil.ReadFile(filename)
+	AssertTrue(t, err == nil, "Recovery: could not read the log")
+	clocks := make(map[string][]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		if host, vc := parseEntryHeader(line); vc != nil {
+			ticks, _ := vc.FindTicks(host)
+			clocks[host] = append(clocks[host], ticks)
+		}
+	}
+	return clocks
+}
+
+func TestResumeClock(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Recovery: could not create directory")
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "node")
+
+	for i := 0; i < 3; i++ {
+		runExecution(logfile, ResumeClock)
+	}
+	ticks := hostClocks(t, logfile+"-Log.txt")[TestPID]
+	// Appending executions do not log an initialization event
+	AssertEquals(t, 13, len(ticks), "Recovery: wrong number of entries")
+	for i, n := range ticks {
+		AssertEquals(t, uint64(i+1), n, "Recovery: clock not resumed")
+	}
+
+	// Restarting the clock makes it go backwards
+	runExecution(logfile, RestartClock)
+	ticks = hostClocks(t, logfile+"-Log.txt")[TestPID]
+	AssertEquals(t, uint64(1), ticks[13], "Recovery: clock not restarted")
+}
+
+func TestNewIdentity(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Recovery: could not create directory")
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "node")
+
+	var gv *GoLog
+	for i := 0; i < 3; i++ {
+		gv = runExecution(logfile, NewIdentity)
+	}
+	AssertEquals(t, `{"TestPID#3":4}`, gv.GetCurrentVC().ReturnVCString(), "Recovery: wrong identity")
+
+	clocks := hostClocks(t, logfile+"-Log.txt")
+	AssertEquals(t, 3, len(clocks), "Recovery: wrong number of hosts")
+	for i, host := range []string{TestPID, TestPID + "#2", TestPID + "#3"} {
+		expected := 4
+		if i == 0 {
+			expected = 5
+		}
+		AssertEquals(t, expected, len(clocks[host]), "Recovery: wrong number of entries for "+host)
+		AssertEquals(t, uint64(1), clocks[host][0], "Recovery: wrong first clock for "+host)
+	}
+}
+
+func TestNewIdentityClockStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Recovery: could not create directory")
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "node")
+	store := NewFileClockStore(filepath.Join(dir, "clock"))
+
+	runExecution(logfile, NewIdentity)
+	// The store is empty: a new identity is taken and saved
+	gv := runExecutionWith(logfile, NewIdentity, store)
+	AssertEquals(t, `{"TestPID#2":4}`, gv.GetCurrentVC().ReturnVCString(), "Recovery: wrong identity")
+	// The saved clock is reloaded under the same identity
+	gv = runExecutionWith(logfile, NewIdentity, store)
+	AssertEquals(t, `{"TestPID#2":8}`, gv.GetCurrentVC().ReturnVCString(), "Recovery: identity not restored")
+
+	clocks := hostClocks(t, logfile+"-Log.txt")
+	AssertEquals(t, 2, len(clocks), "Recovery: wrong number of hosts")
+	AssertEquals(t, 5, len(clocks[TestPID]), "Recovery: wrong number of entries for "+TestPID)
+	for i, n := range clocks[TestPID+"#2"] {
+		AssertEquals(t, uint64(i+1), n, "Recovery: clock went backwards")
+	}
+}
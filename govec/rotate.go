@@ -0,0 +1,340 @@
+package govec
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RotationPolicy controls when a RotatingFileSink closes the log file
+// it writes to and starts a new one. The zero RotationPolicy never
+// rotates. Rotation happens between two entries, so every closed
+// segment holds complete entries and can be read by ShiViz on its own.
+type RotationPolicy struct {
+	// MaxSize is the size in bytes past which the log is rotated, 0
+	// for no limit. An entry larger than MaxSize gets a segment of its
+	// own
+	MaxSize int64
+	// MaxEvents is the number of entries past which the log is
+	// rotated, 0 for no limit
+	MaxEvents int
+	// MaxAge is the age past which the log is rotated, 0 for no limit.
+	// The age is only checked when an entry is written
+	MaxAge time.Duration
+	// Timestamped names closed segments after the time they were
+	// closed at instead of numbering them
+	Timestamped bool
+	// Compress compresses closed segments with gzip
+	Compress bool
+	// MaxSegments is the number of closed segments kept, the oldest
+	// being removed first, 0 to keep them all
+	MaxSegments int
+}
+
+// enabled reports whether p ever rotates the log
+func (p RotationPolicy) enabled() bool {
+	return p.MaxSize > 0 || p.MaxEvents > 0 || p.MaxAge > 0
+}
+
+// timestampFormat names timestamped segments, which sort by name in
+// the order they were closed
+const timestampFormat = "20060102T150405.000000000Z"
+
+// seqDigits is the number of digits of the number of numbered
+// segments. Names holding another number of digits are not taken for
+// segments, as they may belong to other logs
+const seqDigits = 6
+
+// openFile opens the log files of RotatingFileSink, replaced by tests
+var openFile = os.OpenFile
+
+// RotatingFileSink is a Sink which writes log entries to a file and
+// rotates it according to a RotationPolicy. The file being written is
+// always filename; closed segments are renamed by inserting their
+// number or timestamp before the "-Log.txt" suffix, or before the
+// extension of other file names, e.g. "node.000001-Log.txt", and get a
+// ".gz" extension once compressed. Numbered segments hold 6 digits, so
+// at most 999999 of them can be kept. Closed segments are thus merged
+// along with the log file by the govec command. Rotation and
+// compression happen during the Write call which triggers them.
+type RotatingFileSink struct {
+	filename string
+	policy   RotationPolicy
+	// prefix and suffix of the segment names
+	prefix, suffix string
+	file           *os.File
+	size           int64
+	events         int
+	opened         time.Time
+	// seq is the number of the latest numbered segment
+	seq int
+}
+
+// NewRotatingFileSink opens filename for writing and returns a Sink
+// writing to it and rotating it according to policy. The file is
+// created if it does not exist. If appendLog is false any previous
+// content of the file is discarded, along with its closed segments;
+// otherwise they are kept and numbering continues after the last
+// segment.
+func NewRotatingFileSink(filename string, appendLog bool, policy RotationPolicy) (*RotatingFileSink, error) {
+	s := &RotatingFileSink{filename: filename, policy: policy}
+	s.suffix = "-Log.txt"
+	if !strings.HasSuffix(filename, s.suffix) {
+		s.suffix = filepath.Ext(filename)
+	}
+	s.prefix = strings.TrimSuffix(filename, s.suffix) + "."
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		if !appendLog {
+			if err := os.Remove(segment.path); err != nil {
+				return nil, err
+			}
+		} else if segment.seq > s.seq {
+			s.seq = segment.seq
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !appendLog {
+		flags |= os.O_TRUNC
+	}
+	if err := s.open(flags); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) open(flags int) error {
+	file, err := openFile(s.filename, flags, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	s.events = 0
+	s.opened = time.Now()
+	return nil
+}
+
+// Name returns the name of the file written by the sink.
+func (s *RotatingFileSink) Name() string {
+	return s.filename
+}
+
+// Write appends entry to the file, first rotating it if the entry
+// would exceed the policy. If the rotation fails the entry is still
+// written to the current file and the error is returned. If the file
+// could not be reopened by the rotation, the entry is not written and
+// opening the file is tried again on the next Write.
+func (s *RotatingFileSink) Write(entry []byte) (int, error) {
+	var rotateErr error
+	if s.file != nil && s.full(int64(len(entry))) {
+		rotateErr = s.rotate()
+	}
+	if s.file == nil {
+		if err := s.open(os.O_CREATE | os.O_WRONLY | os.O_APPEND); err != nil {
+			if rotateErr != nil {
+				return 0, rotateErr
+			}
+			return 0, err
+		}
+	}
+	n, err := s.file.Write(entry)
+	s.size += int64(n)
+	s.events++
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+// full reports whether writing an entry of size bytes to a non empty
+// file would exceed the policy
+func (s *RotatingFileSink) full(size int64) bool {
+	if s.size == 0 && s.events == 0 {
+		return false
+	}
+	p := s.policy
+	return (p.MaxSize > 0 && s.size+size > p.MaxSize) ||
+		(p.MaxEvents > 0 && s.events >= p.MaxEvents) ||
+		(p.MaxAge > 0 && time.Since(s.opened) >= p.MaxAge)
+}
+
+// Rotate closes the current segment and starts a new one.
+func (s *RotatingFileSink) Rotate() error {
+	return s.rotate()
+}
+
+func (s *RotatingFileSink) rotate() error {
+	if s.file == nil {
+		return s.open(os.O_CREATE | os.O_WRONLY | os.O_APPEND)
+	}
+	err := s.file.Close()
+	// The file is reopened by the next Write if the rotation fails
+	s.file = nil
+	if err != nil {
+		return err
+	}
+	segment := s.nextSegment()
+	if err := os.Rename(s.filename, segment); err != nil {
+		// Keep writing to the current file
+		if openErr := s.open(os.O_CREATE | os.O_WRONLY | os.O_APPEND); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := s.open(os.O_CREATE | os.O_WRONLY | os.O_TRUNC); err != nil {
+		return err
+	}
+
+	if s.policy.Compress {
+		if err := compressFile(segment); err != nil {
+			return err
+		}
+	}
+	return s.prune()
+}
+
+// nextSegment returns the name of the segment being closed
+func (s *RotatingFileSink) nextSegment() string {
+	if !s.policy.Timestamped {
+		s.seq++
+		return fmt.Sprintf("%s%06d%s", s.prefix, s.seq, s.suffix)
+	}
+	now := time.Now().UTC()
+	for {
+		segment := s.prefix + now.Format(timestampFormat) + s.suffix
+		_, err := os.Stat(segment)
+		_, gzErr := os.Stat(segment + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return segment
+		}
+		now = now.Add(time.Nanosecond)
+	}
+}
+
+// prune removes the oldest closed segments beyond the retention count
+func (s *RotatingFileSink) prune() error {
+	if s.policy.MaxSegments <= 0 {
+		return nil
+	}
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+	for len(segments) > s.policy.MaxSegments {
+		if err := os.Remove(segments[0].path); err != nil {
+			return err
+		}
+		segments = segments[1:]
+	}
+	return nil
+}
+
+// segment is a closed segment found on disk
+type segment struct {
+	path string
+	// seq is the number of a numbered segment, 0 for a timestamped
+	// one
+	seq int
+	// stamp is the time a timestamped segment was closed at
+	stamp string
+}
+
+// segments returns the closed segments of the log, oldest first.
+// Numbered segments are deemed older than timestamped ones.
+func (s *RotatingFileSink) segments() ([]segment, error) {
+	dir := filepath.Dir(s.filename)
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Base(s.prefix)
+	var found []segment
+	for _, name := range names {
+		id := strings.TrimSuffix(name, ".gz")
+		if !strings.HasPrefix(id, base) || !strings.HasSuffix(id, s.suffix) || len(id) < len(base)+len(s.suffix) {
+			continue
+		}
+		id = id[len(base) : len(id)-len(s.suffix)]
+		seg := segment{path: filepath.Join(dir, name)}
+		if seq, err := strconv.Atoi(id); err == nil && seq > 0 && len(id) == seqDigits && strings.Trim(id, "0123456789") == "" {
+			seg.seq = seq
+		} else if _, err := time.Parse(timestampFormat, id); err == nil {
+			seg.stamp = id
+		} else {
+			continue
+		}
+		found = append(found, seg)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if (a.seq > 0) != (b.seq > 0) {
+			return a.seq > 0
+		}
+		if a.seq != b.seq {
+			return a.seq < b.seq
+		}
+		return a.stamp < b.stamp
+	})
+	return found, nil
+}
+
+// Close closes the current segment.
+func (s *RotatingFileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// compressFile replaces filename by its gzip compressed version,
+// filename.gz
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(filename)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	return os.Remove(filename)
+}
@@ -0,0 +1,191 @@
+package govec
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shivizEntry matches a log entry with the ShiViz regex used by the
+// govec command
+var shivizEntry = regexp.MustCompile(`(?m)^(\S*) (\{.*\})\n(.*)$`)
+
+func readSegment(t *testing.T, filename string) string {
+	f, err := os.Open(filename)
+	AssertTrue(t, err == nil, "Rotation: could not open "+filename)
+	defer f.Close()
+	if !strings.HasSuffix(filename, ".gz") {
+		content, err := ioutil.ReadAll(f)
+		AssertTrue(t, err == nil, "Rotation: could not read "+filename)
+		return string(content)
+	}
+	zr, err := gzip.NewReader(f)
+	AssertTrue(t, err == nil, "Rotation: segment not compressed")
+	content, err := ioutil.ReadAll(zr)
+	AssertTrue(t, err == nil, "Rotation: could not decompress "+filename)
+	return string(content)
+}
+
+func listLogs(t *testing.T, dir string) []string {
+	f, err := os.Open(dir)
+	AssertTrue(t, err == nil, "Rotation: could not open "+dir)
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	AssertTrue(t, err == nil, "Rotation: could not list "+dir)
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateByEvents(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Rotation: could not create directory")
+	defer os.RemoveAll(dir)
+
+	config := GetDefaultConfig()
+	config.Rotation = RotationPolicy{MaxEvents: 3}
+	gv := InitGoVector(TestPID, filepath.Join(dir, "node"), config)
+	opts := GetDefaultLogOptions()
+	for i := 0; i < 6; i++ {
+		gv.LogLocalEvent("TestEvent", opts)
+	}
+
+	// 7 entries including the initialization event
+	expected := []string{"node-Log.txt", "node.000001-Log.txt", "node.000002-Log.txt"}
+	names := listLogs(t, dir)
+	AssertEquals(t, strings.Join(expected, " "), strings.Join(names, " "), "Rotation: wrong segments")
+
+	for i, name := range names {
+		content := readSegment(t, filepath.Join(dir, name))
+		n := len(shivizEntry.FindAllString(content, -1))
+		if i == 0 {
+			AssertEquals(t, 1, n, "Rotation: wrong number of entries in the log")
+		} else {
+			AssertEquals(t, 3, n, "Rotation: wrong number of entries in "+name)
+		}
+	}
+	AssertTrue(t, strings.Contains(readSegment(t, filepath.Join(dir, "node.000001-Log.txt")), "Initialization Complete"), "Rotation: segments out of order")
+
+	// Appending continues the numbering, otherwise old segments go
+	config.AppendLog = true
+	gv = InitGoVector(TestPID, filepath.Join(dir, "node"), config)
+	for i := 0; i < 3; i++ {
+		gv.LogLocalEvent("TestEvent", opts)
+	}
+	AssertEquals(t, "node-Log.txt node.000001-Log.txt node.000002-Log.txt node.000003-Log.txt", strings.Join(listLogs(t, dir), " "), "Rotation: numbering not continued")
+
+	config.AppendLog = false
+	gv = InitGoVector(TestPID, filepath.Join(dir, "node"), config)
+	AssertEquals(t, "node-Log.txt", strings.Join(listLogs(t, dir), " "), "Rotation: old segments kept")
+}
+
+func TestRotateBySizeCompressed(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Rotation: could not create directory")
+	defer os.RemoveAll(dir)
+
+	sink, err := NewRotatingFileSink(filepath.Join(dir, "node-Log.txt"), false, RotationPolicy{MaxSize: 10, Compress: true, MaxSegments: 2})
+	AssertTrue(t, err == nil, "Rotation: could not open sink")
+	for _, entry := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddddddddddd\n", "eeee\n"} {
+		_, err := sink.Write([]byte(entry))
+		AssertTrue(t, err == nil, "Rotation: write failed")
+	}
+	AssertTrue(t, sink.Close() == nil, "Rotation: close failed")
+
+	// The oldest segment, holding a and b, was removed
+	names := listLogs(t, dir)
+	AssertEquals(t, "node-Log.txt node.000002-Log.txt.gz node.000003-Log.txt.gz", strings.Join(names, " "), "Rotation: wrong segments")
+	AssertEquals(t, "cccc\n", readSegment(t, filepath.Join(dir, names[1])), "Rotation: wrong segment content")
+	AssertEquals(t, "dddddddddddd\n", readSegment(t, filepath.Join(dir, names[2])), "Rotation: large entry not in a segment of its own")
+	AssertEquals(t, "eeee\n", readSegment(t, filepath.Join(dir, names[0])), "Rotation: wrong log content")
+}
+
+func TestRotateKeepsOtherLogs(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Rotation: could not create directory")
+	defer os.RemoveAll(dir)
+
+	// Logs of other processes whose names look like segments
+	others := []string{"node.12345-Log.txt", "node.1234567-Log.txt", "node.+12345-Log.txt"}
+	for _, name := range others {
+		AssertTrue(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600) == nil, "Rotation: could not create "+name)
+	}
+
+	sink, err := NewRotatingFileSink(filepath.Join(dir, "node-Log.txt"), false, RotationPolicy{MaxEvents: 1, MaxSegments: 1})
+	AssertTrue(t, err == nil, "Rotation: could not open sink")
+	for _, entry := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		_, err := sink.Write([]byte(entry))
+		AssertTrue(t, err == nil, "Rotation: write failed")
+	}
+	AssertTrue(t, sink.Close() == nil, "Rotation: close failed")
+
+	expected := append([]string{"node-Log.txt", "node.000002-Log.txt"}, others...)
+	sort.Strings(expected)
+	AssertEquals(t, strings.Join(expected, " "), strings.Join(listLogs(t, dir), " "), "Rotation: other logs removed")
+}
+
+func TestRotateReopenFailure(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Rotation: could not create directory")
+	defer os.RemoveAll(dir)
+
+	sink, err := NewRotatingFileSink(filepath.Join(dir, "node-Log.txt"), false, RotationPolicy{MaxEvents: 1})
+	AssertTrue(t, err == nil, "Rotation: could not open sink")
+	_, err = sink.Write([]byte("aaaa\n"))
+	AssertTrue(t, err == nil, "Rotation: write failed")
+
+	// Opening fails during the rotation and the retry of the same Write
+	failures := 2
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if failures > 0 {
+			failures--
+			return nil, os.ErrPermission
+		}
+		return os.OpenFile(name, flag, perm)
+	}
+	defer func() { openFile = os.OpenFile }()
+
+	_, err = sink.Write([]byte("bbbb\n"))
+	AssertTrue(t, err != nil, "Rotation: reopen failure not reported")
+	_, err = sink.Write([]byte("cccc\n"))
+	AssertTrue(t, err == nil, "Rotation: file not reopened")
+	AssertTrue(t, sink.Close() == nil, "Rotation: close failed")
+
+	AssertEquals(t, "node-Log.txt node.000001-Log.txt", strings.Join(listLogs(t, dir), " "), "Rotation: wrong segments")
+	AssertEquals(t, "aaaa\n", readSegment(t, filepath.Join(dir, "node.000001-Log.txt")), "Rotation: wrong segment content")
+	AssertEquals(t, "cccc\n", readSegment(t, filepath.Join(dir, "node-Log.txt")), "Rotation: wrong log content")
+}
+
+func TestRotateTimestamped(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "Rotation: could not create directory")
+	defer os.RemoveAll(dir)
+
+	sink, err := NewRotatingFileSink(filepath.Join(dir, "node.log"), false, RotationPolicy{MaxAge: time.Hour, Timestamped: true})
+	AssertTrue(t, err == nil, "Rotation: could not open sink")
+	sink.Write([]byte("a\n"))
+	AssertEquals(t, 1, len(listLogs(t, dir)), "Rotation: rotated before MaxAge")
+	for i := 0; i < 2; i++ {
+		AssertTrue(t, sink.Rotate() == nil, "Rotation: Rotate failed")
+		sink.Write([]byte("b\n"))
+	}
+	sink.Close()
+
+	names := listLogs(t, dir)
+	AssertEquals(t, 3, len(names), "Rotation: wrong number of segments")
+	stamped := regexp.MustCompile(`^node\.\d{8}T\d{6}\.\d{9}Z\.log$`)
+	AssertTrue(t, stamped.MatchString(names[0]), "Rotation: segment not timestamped "+names[0])
+	AssertTrue(t, stamped.MatchString(names[1]), "Rotation: segment not timestamped "+names[1])
+	AssertEquals(t, "node.log", names[2], "Rotation: log file renamed")
+	AssertEquals(t, "a\n", readSegment(t, filepath.Join(dir, names[0])), "Rotation: segments out of order")
+}
@@ -0,0 +1,168 @@
+package govec
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink is a destination for log entries. GoLog calls Write once for
+// every entry it logs, so a sink can rely on each call holding exactly
+// one complete entry. Close releases any resource held by the sink.
+type Sink interface {
+	io.Writer
+	io.Closer
+}
+
+// writerSink adapts an io.Writer which it does not own into a Sink.
+type writerSink struct {
+	w io.Writer
+}
+
+// NewWriterSink returns a Sink which writes every log entry to w.
+// Closing the sink does not close w.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+// NewStdoutSink returns a Sink which writes every log entry to
+// standard output.
+func NewStdoutSink() Sink {
+	return NewWriterSink(os.Stdout)
+}
+
+// NewStderrSink returns a Sink which writes every log entry to
+// standard error.
+func NewStderrSink() Sink {
+	return NewWriterSink(os.Stderr)
+}
+
+func (s *writerSink) Write(entry []byte) (int, error) {
+	return s.w.Write(entry)
+}
+
+func (s *writerSink) Close() error {
+	return nil
+}
+
+// FileSink is a Sink which writes log entries to a file. The file is
+// opened once and kept open until the sink is closed.
+type FileSink struct {
+	file *os.File
+}
+
+// NewFileSink opens filename for writing and returns a Sink writing to
+// it. The file is created if it does not exist. If appendLog is false
+// any previous content of the file is discarded.
+func NewFileSink(filename string, appendLog bool) (*FileSink, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !appendLog {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(filename, flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Name returns the name of the file written by the sink.
+func (s *FileSink) Name() string {
+	return s.file.Name()
+}
+
+// Write appends entry to the file.
+func (s *FileSink) Write(entry []byte) (int, error) {
+	return s.file.Write(entry)
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// RingSink is an in-memory Sink which keeps the most recent log
+// entries, discarding the oldest ones once it is full. It is safe for
+// concurrent use.
+type RingSink struct {
+	mutex   sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// NewRingSink returns a RingSink holding at most size entries.
+func NewRingSink(size int) *RingSink {
+	if size < 1 {
+		size = 1
+	}
+	return &RingSink{entries: make([][]byte, size)}
+}
+
+// Write stores a copy of entry, evicting the oldest entry if the ring
+// is full.
+func (s *RingSink) Write(entry []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[s.next] = append([]byte(nil), entry...)
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return len(entry), nil
+}
+
+// Entries returns the stored entries, oldest first.
+func (s *RingSink) Entries() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var ordered [][]byte
+	if s.full {
+		ordered = append(ordered, s.entries[s.next:]...)
+	}
+	ordered = append(ordered, s.entries[:s.next]...)
+
+	entries := make([]string, len(ordered))
+	for i, entry := range ordered {
+		entries[i] = string(entry)
+	}
+	return entries
+}
+
+// Close is a no-op; the stored entries remain available.
+func (s *RingSink) Close() error {
+	return nil
+}
+
+// multiSink duplicates every log entry to several sinks.
+type multiSink []Sink
+
+// NewMultiSink returns a Sink which writes every log entry to all of
+// the given sinks. Closing it closes all of them.
+func NewMultiSink(sinks ...Sink) Sink {
+	return multiSink(append([]Sink(nil), sinks...))
+}
+
+// Write writes entry to every sink, even if some of them fail, and
+// returns the first error encountered.
+func (m multiSink) Write(entry []byte) (n int, err error) {
+	for _, s := range m {
+		if _, werr := s.Write(entry); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// Close closes every sink and returns the first error encountered.
+func (m multiSink) Close() (err error) {
+	for _, s := range m {
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package govec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRingSinkEviction(t *testing.T) {
+
+	ring := NewRingSink(2)
+	ring.Write([]byte("a"))
+	AssertEquals(t, 1, len(ring.Entries()), "RingSink: wrong number of entries")
+
+	ring.Write([]byte("b"))
+	ring.Write([]byte("c"))
+
+	entries := ring.Entries()
+	AssertEquals(t, 2, len(entries), "RingSink: wrong number of entries")
+	AssertEquals(t, "b", entries[0], "RingSink: oldest entry not evicted")
+	AssertEquals(t, "c", entries[1], "RingSink: newest entry missing")
+}
+
+func TestMultiSink(t *testing.T) {
+
+	var buf bytes.Buffer
+	ring := NewRingSink(4)
+	multi := NewMultiSink(NewWriterSink(&buf), ring)
+
+	multi.Write([]byte("entry\n"))
+
+	AssertEquals(t, "entry\n", buf.String(), "MultiSink: writer sink not written")
+	AssertEquals(t, 1, len(ring.Entries()), "MultiSink: ring sink not written")
+}
+
+func TestLogToSinks(t *testing.T) {
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{ring}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	gv.LogLocalEvent("TestMessage1", opts)
+
+	entries := ring.Entries()
+	AssertEquals(t, 2, len(entries), "Sinks: expected one entry per event")
+	AssertEquals(t, "TestPID {\"TestPID\":2}\nINFO TestMessage1\n", entries[1], "Sinks: wrong entry")
+}
+
+func TestBufferedSinks(t *testing.T) {
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Buffered = true
+	config.Sinks = []Sink{ring}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	gv.LogLocalEvent("TestMessage1", opts)
+	AssertEquals(t, 0, len(ring.Entries()), "Sinks: buffered entries written before Flush")
+
+	AssertTrue(t, gv.Flush(), "Sinks: Flush failed")
+	entries := ring.Entries()
+	AssertEquals(t, 2, len(entries), "Sinks: buffered entries not written by Flush")
+	AssertTrue(t, strings.HasSuffix(entries[1], "TestMessage1\n"), "Sinks: wrong entry")
+}
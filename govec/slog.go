@@ -0,0 +1,166 @@
+//go:build go1.21
+// +build go1.21
+
+package govec
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ClockKey is the key of the attribute holding the vector clock in
+// records forwarded by SlogHandler.
+const ClockKey = "vclock"
+
+// LevelFromPriority returns the slog.Level matching a LogPriority.
+// FATAL, which has no slog counterpart, is mapped four levels above
+// slog.LevelError.
+func LevelFromPriority(priority LogPriority) slog.Level {
+	switch priority {
+	case DEBUG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARNING:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// PriorityFromLevel returns the LogPriority matching a slog.Level.
+// Levels in between two slog levels map to the priority of the lower
+// one.
+func PriorityFromLevel(level slog.Level) LogPriority {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARNING
+	case level < slog.LevelError+4:
+		return ERROR
+	default:
+		return FATAL
+	}
+}
+
+// SlogHandler is a slog.Handler which logs every record as a local
+// event of a GoLog, ticking its vector clock exactly like
+// LogLocalEvent. Records are then forwarded to an optional downstream
+// handler with the vector clock of the event attached under ClockKey,
+// at the top level of the record whatever its groups.
+type SlogHandler struct {
+	gv     *GoLog
+	next   slog.Handler
+	attrs  []Attr
+	prefix string
+	// root is the downstream handler given to NewSlogHandler, and
+	// derive turn it into next by applying WithAttrs and WithGroup in
+	// order
+	root   slog.Handler
+	derive []func(slog.Handler) slog.Handler
+}
+
+// NewSlogHandler returns a SlogHandler logging to gv. If next is not
+// nil every record is also forwarded to it.
+func NewSlogHandler(gv *GoLog, next slog.Handler) *SlogHandler {
+	return &SlogHandler{gv: gv, next: next, root: next}
+}
+
+// Enabled reports whether records of the given level are logged by
+// the GoLog or by the downstream handler.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	h.gv.mutex.RLock()
+	priority := h.gv.priority
+	h.gv.mutex.RUnlock()
+	if PriorityFromLevel(level) >= priority {
+		return true
+	}
+	return h.next != nil && h.next.Enabled(ctx, level)
+}
+
+// Handle logs r as a local event and forwards it, stamped with the
+// vector clock of the event, to the downstream handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	opts := GoLogOptions{Priority: PriorityFromLevel(r.Level)}
+	opts.Attrs = append(opts.Attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		opts.Attrs = appendSlogAttr(opts.Attrs, h.prefix, a)
+		return true
+	})
+
+	vc, _ := h.gv.logLocalEvent(r.Message, opts)
+
+	if h.next == nil || !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if vc == nil {
+		return h.next.Handle(ctx, r)
+	}
+	if h.prefix == "" {
+		r = r.Clone()
+		r.AddAttrs(slog.Any(ClockKey, vc))
+		return h.next.Handle(ctx, r)
+	}
+	// Attach the clock before the groups are opened
+	next := h.root.WithAttrs([]slog.Attr{slog.Any(ClockKey, vc)})
+	for _, derive := range h.derive {
+		next = derive(next)
+	}
+	return next.Handle(ctx, r)
+}
+
+// derived returns a copy of h whose downstream handler is derived with
+// derive
+func (h *SlogHandler) derived(derive func(slog.Handler) slog.Handler) *SlogHandler {
+	h2 := *h
+	if h.next != nil {
+		h2.next = derive(h.next)
+		h2.derive = append(append([]func(slog.Handler) slog.Handler(nil), h.derive...), derive)
+	}
+	return &h2
+}
+
+// WithAttrs returns a handler which attaches attrs to every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := h.derived(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+	h2.attrs = append([]Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendSlogAttr(h2.attrs, h.prefix, a)
+	}
+	return h2
+}
+
+// WithGroup returns a handler which qualifies the keys of all
+// following attributes with name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.derived(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+	h2.prefix = h.prefix + name + "."
+	return h2
+}
+
+// appendSlogAttr flattens a into GoLog attributes, qualifying the keys
+// of groups with their name.
+func appendSlogAttr(dst []Attr, prefix string, a slog.Attr) []Attr {
+	value := a.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		if a.Key == "" {
+			return dst
+		}
+		return append(dst, Attr{Key: prefix + a.Key, Value: value.Any()})
+	}
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	for _, ga := range value.Group() {
+		dst = appendSlogAttr(dst, prefix, ga)
+	}
+	return dst
+}
@@ -0,0 +1,52 @@
+//go:build go1.21
+// +build go1.21
+
+package govec
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestPriorityLevelMapping(t *testing.T) {
+
+	for _, priority := range []LogPriority{DEBUG, INFO, WARNING, ERROR, FATAL} {
+		AssertEquals(t, priority, PriorityFromLevel(LevelFromPriority(priority)), "Slog: priority does not round trip")
+	}
+	AssertEquals(t, WARNING, PriorityFromLevel(slog.LevelWarn+1), "Slog: wrong priority for intermediate level")
+}
+
+func TestSlogHandler(t *testing.T) {
+
+	ring := NewRingSink(8)
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{ring}
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(gv, slog.NewJSONHandler(&buf, nil)))
+
+	logger.With("service", "test").WithGroup("req").Warn("TestMessage1", "id", 7)
+	logger.Debug("TestMessage2")
+
+	n, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertEquals(t, uint64(2), n, "Slog: Clock value not incremented once")
+
+	entries := ring.Entries()
+	AssertEquals(t, "TestPID {\"TestPID\":2}\nWARNING TestMessage1 service=test req.id=7\n", entries[len(entries)-1], "Slog: wrong entry")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	// The clock is at the top level, whatever the groups
+	AssertEquals(t, float64(2), record[ClockKey].(map[string]interface{})[TestPID], "Slog: clock not forwarded")
+	AssertEquals(t, "test", record["service"], "Slog: attribute not forwarded")
+	group := record["req"].(map[string]interface{})
+	AssertEquals(t, float64(7), group["id"], "Slog: group not forwarded")
+	_, found := group[ClockKey]
+	AssertTrue(t, !found, "Slog: clock qualified by the group")
+}
@@ -0,0 +1,239 @@
+package govec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+// ClockStore persists the vector clock of a GoLog, so that a restarted
+// process carries on from the clock it had instead of starting over,
+// which would break causality with its peers. See
+// GoLogConfig.ClockStore.
+type ClockStore interface {
+	// Load returns the saved clock, nil if none was saved
+	Load() (vclock.VClock, error)
+	// Save replaces the saved clock by vc
+	Save(vc vclock.VClock) error
+}
+
+// FileClockStore is a ClockStore keeping the clock in a checkpoint
+// file, in the text format parsed by vclock.Parse.
+type FileClockStore struct {
+	filename string
+}
+
+// NewFileClockStore returns a ClockStore keeping the clock in filename.
+func NewFileClockStore(filename string) *FileClockStore {
+	return &FileClockStore{filename: filename}
+}
+
+// Load reads the clock from the checkpoint file, nil is returned if
+// the file does not exist.
+func (s *FileClockStore) Load() (vclock.VClock, error) {
+	content, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vclock.Parse(strings.TrimSpace(string(content)))
+}
+
+// Save atomically replaces the checkpoint file: the clock is written
+// to a temporary file in the same directory, synced to disk and
+// renamed over the checkpoint file, which is thus never left half
+// written, and the directory is synced for the rename to persist.
+func (s *FileClockStore) Save(vc vclock.VClock) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.filename), filepath.Base(s.filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(vc.ReturnVCString() + "\n")
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(s.filename))
+}
+
+// syncDir syncs the directory dir to disk. Directories cannot be synced
+// on Windows, where renames are persisted by the file system itself.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LogClockStore is a read-only ClockStore loading the clock of a
+// process from the last entry it logged in a log file, written by the
+// ShiVizFormatter, with or without timestamps, or by the
+// JSONFormatter. It suits a GoLog appending to its log, which already
+// records every clock: Save does nothing.
+type LogClockStore struct {
+	filename string
+	pid      string
+}
+
+// NewLogClockStore returns a ClockStore loading the clock of process
+// pid from the log file filename, including its "-Log.txt" suffix.
+func NewLogClockStore(filename string, pid string) *LogClockStore {
+	return &LogClockStore{filename: filename, pid: pid}
+}
+
+// Load returns the clock of the last entry of the process in the log,
+// nil if the log does not exist or holds no such entry. The log is
+// read backwards from its end.
+func (s *LogClockStore) Load() (vclock.VClock, error) {
+	f, err := os.Open(s.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, vc, err := lastEntry(f, func(host string) bool { return host == s.pid })
+	return vc, err
+}
+
+// Save does nothing, the log records the clock already.
+func (s *LogClockStore) Save(vc vclock.VClock) error {
+	return nil
+}
+
+// tailChunk is the amount of log read at once by lastEntry
+const tailChunk = 64 * 1024
+
+// lastEntry returns the host and clock of the last entry of f logged
+// by a host accepted by match, reading f backwards by chunks. A nil
+// clock is returned if there is no such entry.
+func lastEntry(f *os.File, match func(host string) bool) (string, vclock.VClock, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", nil, err
+	}
+	end := info.Size()
+	// partial is the end of the line cut by the previous chunk
+	var partial []byte
+	for end > 0 {
+		start := end - tailChunk
+		if start < 0 {
+			start = 0
+		}
+		chunk := make([]byte, end-start, int(end-start)+len(partial))
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return "", nil, err
+		}
+		chunk = append(chunk, partial...)
+
+		lines := bytes.Split(chunk, []byte("\n"))
+		// The first line may be cut, unless the file starts there
+		first := 1
+		if start == 0 {
+			first = 0
+		}
+		for i := len(lines) - 1; i >= first; i-- {
+			if host, vc := parseEntryHeader(string(lines[i])); vc != nil && match(host) {
+				return host, vc, nil
+			}
+		}
+		partial = lines[0]
+		end = start
+	}
+	return "", nil, nil
+}
+
+// parseEntryHeader returns the host and clock held by a line of log,
+// a nil clock if the line holds none. The line is either the first
+// line of a ShiViz entry, optionally prefixed by a TSViz timestamp, or
+// a JSON entry.
+func parseEntryHeader(line string) (string, vclock.VClock) {
+	line = strings.TrimSuffix(line, "\r")
+	if strings.HasPrefix(line, "{") {
+		var je jsonEvent
+		if json.Unmarshal([]byte(line), &je) != nil || je.Pid == "" || je.VC == nil {
+			return "", nil
+		}
+		return je.Pid, vclock.VClock(je.VC)
+	}
+
+	brace := strings.Index(line, " {")
+	if brace < 0 {
+		return "", nil
+	}
+	fields := strings.Split(line[:brace], " ")
+	if len(fields) > 2 || fields[len(fields)-1] == "" {
+		return "", nil
+	}
+	if len(fields) == 2 && (fields[0] == "" || strings.Trim(fields[0], "0123456789") != "") {
+		return "", nil
+	}
+	vc, err := vclock.Parse(line[brace+1:])
+	if err != nil {
+		return "", nil
+	}
+	return fields[len(fields)-1], vc
+}
+
+// checkpoint saves the clock to the ClockStore once every
+// checkpointInterval calls. The caller must hold the mutex.
+func (gv *GoLog) checkpoint() {
+	if gv.store == nil {
+		return
+	}
+	gv.unsaved++
+	if gv.unsaved >= gv.checkpointInterval {
+		gv.reportSaveError(gv.saveClock())
+	}
+}
+
+// saveClock saves the clock to the ClockStore, returning an error of
+// kind ErrClockSave if it fails. The unsaved ticks are then saved by
+// the next checkpoint. The caller must hold the mutex.
+func (gv *GoLog) saveClock() error {
+	if err := gv.store.Save(gv.currentVC.Copy()); err != nil {
+		return newError(ErrClockSave, err)
+	}
+	gv.unsaved = 0
+	return nil
+}
+
+// reportSaveError reports err, if not nil, to the OnSaveError handler,
+// or else prints it. The caller must hold the mutex.
+func (gv *GoLog) reportSaveError(err error) {
+	if err == nil {
+		return
+	}
+	if gv.onSaveError != nil {
+		gv.onSaveError(err)
+	} else {
+		warnings.Println(err)
+	}
+}
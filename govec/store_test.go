@@ -0,0 +1,174 @@
+package govec
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec/vclock"
+)
+
+func TestFileClockStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "ClockStore: could not create directory")
+	defer os.RemoveAll(dir)
+
+	store := NewFileClockStore(filepath.Join(dir, "clock"))
+	vc, err := store.Load()
+	AssertTrue(t, err == nil && vc == nil, "ClockStore: clock loaded from a missing file")
+
+	saved := vclock.VClock{"a": 3, "b": 7}
+	AssertTrue(t, store.Save(saved) == nil, "ClockStore: Save failed")
+	AssertTrue(t, store.Save(saved) == nil, "ClockStore: Save failed")
+	vc, err = store.Load()
+	AssertTrue(t, err == nil, "ClockStore: Load failed")
+	AssertEquals(t, saved.ReturnVCString(), vc.ReturnVCString(), "ClockStore: wrong clock loaded")
+
+	names, _ := ioutil.ReadDir(dir)
+	AssertEquals(t, 1, len(names), "ClockStore: temporary file left behind")
+}
+
+func TestCheckpoint(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "ClockStore: could not create directory")
+	defer os.RemoveAll(dir)
+
+	store := NewFileClockStore(filepath.Join(dir, "clock"))
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{NewRingSink(8)}
+	config.ClockStore = store
+	config.CheckpointInterval = 3
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	opts := GetDefaultLogOptions()
+
+	// The initialization event is the first tick
+	gv.LogLocalEvent("TestEvent", opts)
+	vc, _ := store.Load()
+	AssertTrue(t, vc == nil, "ClockStore: saved before the interval")
+	gv.LogLocalEvent("TestEvent", opts)
+	vc, _ = store.Load()
+	AssertEquals(t, `{"TestPID":3}`, vc.ReturnVCString(), "ClockStore: not saved after the interval")
+
+	sender := InitGoVector("Sender", "TestLogFile", GetDefaultConfig())
+	var n int
+	gv.UnpackReceive("TestReceive", sender.PrepareSend("TestSend", 1, opts), &n, opts)
+	gv.Close()
+	vc, _ = store.Load()
+	AssertEquals(t, `{"Sender":2, "TestPID":5}`, vc.ReturnVCString(), "ClockStore: not saved on Close")
+
+	// A restarted process carries on from the saved clock, advanced by
+	// the ticks a crash may have lost
+	config.InitialVC = vclock.VClock{TestPID: 100}
+	gv = InitGoVector(TestPID, "TestLogFile", config)
+	AssertEquals(t, `{"Sender":2, "TestPID":8}`, gv.GetCurrentVC().ReturnVCString(), "ClockStore: saved clock not reloaded")
+	vc, _ = store.Load()
+	AssertEquals(t, `{"Sender":2, "TestPID":7}`, vc.ReturnVCString(), "ClockStore: advanced clock not saved")
+
+	// Crash between two checkpoints: the ticks past the last one are
+	// lost, gv is not closed
+	gv.LogLocalEvent("TestEvent", opts)
+	crashed, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	vc, _ = store.Load()
+	AssertEquals(t, `{"Sender":2, "TestPID":7}`, vc.ReturnVCString(), "ClockStore: saved before the interval")
+	AssertEquals(t, uint64(9), crashed, "ClockStore: wrong clock before the crash")
+
+	gv = InitGoVector(TestPID, "TestLogFile", config)
+	restarted, _ := gv.GetCurrentVC().FindTicks(TestPID)
+	AssertTrue(t, restarted > crashed, "ClockStore: clock went back after a crash")
+}
+
+func TestLogClockStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "govec")
+	AssertTrue(t, err == nil, "ClockStore: could not create directory")
+	defer os.RemoveAll(dir)
+
+	formatters := []Formatter{ShiVizFormatter{}, ShiVizFormatter{UseTimestamps: true}, JSONFormatter{}}
+	for _, formatter := range formatters {
+		config := GetDefaultConfig()
+		config.Formatter = formatter
+		logfile := filepath.Join(dir, "node")
+		gv := InitGoVector("1", logfile, config)
+		// Enough events for the log to be read in several chunks
+		for i := 0; i < 3000; i++ {
+			gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+		}
+		gv.Close()
+
+		vc, err := NewLogClockStore(logfile+"-Log.txt", "1").Load()
+		AssertTrue(t, err == nil, "LogClockStore: Load failed")
+		AssertEquals(t, `{"1":3002}`, vc.ReturnVCString(), "LogClockStore: wrong clock loaded")
+
+		vc, err = NewLogClockStore(logfile+"-Log.txt", "2").Load()
+		AssertTrue(t, err == nil && vc == nil, "LogClockStore: clock of another process loaded")
+	}
+
+	// Entries of other processes and messages looking like clocks are
+	// skipped
+	log := "a {\"a\":1}\nInitialization Complete\nb {\"b\":1}\nINFO a {\"a\":9}\n"
+	AssertTrue(t, ioutil.WriteFile(filepath.Join(dir, "mixed"), []byte(log), 0600) == nil, "LogClockStore: could not write log")
+	vc, _ := NewLogClockStore(filepath.Join(dir, "mixed"), "a").Load()
+	AssertEquals(t, `{"a":1}`, vc.ReturnVCString(), "LogClockStore: wrong entry picked")
+	vc, _ = NewLogClockStore(filepath.Join(dir, "missing"), "a").Load()
+	AssertTrue(t, vc == nil, "LogClockStore: clock loaded from a missing log")
+}
+
+// failingStore is a ClockStore whose saves fail
+type failingStore struct{}
+
+func (failingStore) Load() (vclock.VClock, error) { return nil, nil }
+
+func (failingStore) Save(vc vclock.VClock) error { return errors.New("read-only store") }
+
+func TestSaveErrors(t *testing.T) {
+
+	var saveErrs []error
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.Sinks = []Sink{NewRingSink(8)}
+	config.ClockStore = failingStore{}
+	config.CheckpointInterval = 2
+	config.OnSaveError = func(err error) { saveErrs = append(saveErrs, err) }
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+
+	// The initialization event and this one reach the interval, the
+	// next save is tried on every tick
+	gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+	gv.LogLocalEvent("TestEvent", GetDefaultLogOptions())
+	AssertEquals(t, 2, len(saveErrs), "ClockStore: failed checkpoints not reported")
+	AssertTrue(t, errors.Is(saveErrs[0], ErrClockSave), "ClockStore: wrong error kind")
+
+	err := gv.Close()
+	AssertTrue(t, errors.Is(err, ErrClockSave), "ClockStore: failed final save not returned by Close")
+	AssertEquals(t, 2, len(saveErrs), "ClockStore: final save reported to OnSaveError")
+}
+
+// countingStore is a ClockStore counting its saves
+type countingStore struct{ saves int }
+
+func (s *countingStore) Load() (vclock.VClock, error) { return nil, nil }
+
+func (s *countingStore) Save(vc vclock.VClock) error { s.saves++; return nil }
+
+func TestCheckpointEncodeError(t *testing.T) {
+
+	store := &countingStore{}
+	config := GetDefaultConfig()
+	config.LogToFile = false
+	config.ClockStore = store
+	config.EncodingStrategy = func(interface{}) ([]byte, error) {
+		return nil, errors.New("cannot encode")
+	}
+	config.DecodingStrategy = defaultDecoder
+	gv := InitGoVector(TestPID, "TestLogFile", config)
+	saves := store.saves
+
+	_, err := gv.PrepareSendE("TestMessage1", 1337, GetDefaultLogOptions())
+	AssertTrue(t, errors.Is(err, ErrEncode), "ClockStore: expected an encode error")
+	AssertEquals(t, saves, store.saves, "ClockStore: clock saved by a failed send")
+}
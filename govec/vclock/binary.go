@@ -0,0 +1,176 @@
+package vclock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// binaryVersion is the version of the compact binary encoding written
+// by MarshalBinary
+const binaryVersion = 1
+
+// flagDictionary marks binary clocks whose ids refer to a Dictionary
+const flagDictionary = 1
+
+// ErrMalformedBinary is returned when decoding a binary clock which
+// is truncated or otherwise invalid.
+var ErrMalformedBinary = errors.New("vclock: malformed binary clock")
+
+// Dictionary maps process ids to small integers, so that binary clocks
+// exchanged between peers sharing the same dictionary carry an index
+// instead of the full id. Ids missing from the dictionary are still
+// encoded in full. A Dictionary must not be modified once in use.
+type Dictionary struct {
+	ids   []string
+	index map[string]int
+}
+
+// NewDictionary returns a Dictionary of the given ids. Peers must build
+// their dictionaries from the same ids in the same order.
+func NewDictionary(ids ...string) *Dictionary {
+	d := &Dictionary{index: make(map[string]int, len(ids))}
+	for _, id := range ids {
+		if _, found := d.index[id]; !found {
+			d.index[id] = len(d.ids)
+			d.ids = append(d.ids, id)
+		}
+	}
+	return d
+}
+
+// MarshalBinary returns the compact binary encoding of the clock. The
+// encoding starts with a version byte and a flags byte, followed by
+// the varint encoded number of entries, and for every entry the
+// length prefixed id and the varint encoded clock value. Entries are
+// sorted by id so equal clocks have equal encodings. Since VClock
+// implements encoding.BinaryMarshaler, gob uses this encoding for
+// VClock values; Bytes and FromBytes keep gob encoding a plain map.
+func (vc VClock) MarshalBinary() ([]byte, error) {
+	return vc.MarshalBinaryWith(nil)
+}
+
+// MarshalBinaryWith is like MarshalBinary, but ids found in dict are
+// encoded as their index in the dictionary. A nil dict is allowed.
+func (vc VClock) MarshalBinaryWith(dict *Dictionary) ([]byte, error) {
+	ids := make([]string, 0, len(vc))
+	size := 2 + binary.MaxVarintLen64
+	for id := range vc {
+		ids = append(ids, id)
+		size += len(id) + 2*binary.MaxVarintLen64
+	}
+	sort.Strings(ids)
+
+	var flags byte
+	if dict != nil {
+		flags |= flagDictionary
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, binaryVersion, flags)
+	buf = appendUvarint(buf, uint64(len(ids)))
+	for _, id := range ids {
+		if dict != nil {
+			// Index 0 introduces an id encoded in full
+			if i, found := dict.index[id]; found {
+				buf = appendUvarint(buf, uint64(i)+1)
+				buf = appendUvarint(buf, vc[id])
+				continue
+			}
+			buf = appendUvarint(buf, 0)
+		}
+		buf = appendUvarint(buf, uint64(len(id)))
+		buf = append(buf, id...)
+		buf = appendUvarint(buf, vc[id])
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a clock encoded by MarshalBinary, replacing
+// the content of the callee.
+func (vc *VClock) UnmarshalBinary(data []byte) error {
+	return vc.UnmarshalBinaryWith(data, nil)
+}
+
+// UnmarshalBinaryWith decodes a clock encoded by MarshalBinaryWith,
+// resolving indexes with dict, replacing the content of the callee.
+func (vc *VClock) UnmarshalBinaryWith(data []byte, dict *Dictionary) error {
+	if len(data) < 2 {
+		return ErrMalformedBinary
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("vclock: unsupported binary clock version %d", data[0])
+	}
+	flags := data[1]
+	if flags&^flagDictionary != 0 {
+		return fmt.Errorf("vclock: unsupported binary clock flags %#x", flags)
+	}
+	if flags&flagDictionary != 0 && dict == nil {
+		return errors.New("vclock: binary clock requires a dictionary")
+	}
+	data = data[2:]
+
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+	// Every entry takes at least two bytes
+	if n > uint64(len(data)/2) {
+		return ErrMalformedBinary
+	}
+
+	clock := make(VClock, n)
+	for i := uint64(0); i < n; i++ {
+		var id string
+		index := uint64(0)
+		if flags&flagDictionary != 0 {
+			if index, data, err = readUvarint(data); err != nil {
+				return err
+			}
+		}
+		if index > 0 {
+			if index > uint64(len(dict.ids)) {
+				return fmt.Errorf("vclock: binary clock refers to unknown dictionary entry %d", index-1)
+			}
+			id = dict.ids[index-1]
+		} else {
+			var length uint64
+			if length, data, err = readUvarint(data); err != nil {
+				return err
+			}
+			if length > uint64(len(data)) {
+				return ErrMalformedBinary
+			}
+			id, data = string(data[:length]), data[length:]
+		}
+
+		var ticks uint64
+		if ticks, data, err = readUvarint(data); err != nil {
+			return err
+		}
+		if _, found := clock[id]; found {
+			return fmt.Errorf("vclock: binary clock holds id %q twice", id)
+		}
+		clock[id] = ticks
+	}
+	if len(data) != 0 {
+		return ErrMalformedBinary
+	}
+
+	*vc = clock
+	return nil
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	x, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, ErrMalformedBinary
+	}
+	return x, data[n:], nil
+}
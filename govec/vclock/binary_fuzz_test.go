@@ -0,0 +1,41 @@
+//go:build go1.18
+// +build go1.18
+
+package vclock
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	seed, _ := VClock{"a": 1, "b": 300, "process-42": 1 << 40}.MarshalBinary()
+	f.Add(seed)
+	f.Add([]byte{binaryVersion, 0, 0})
+	f.Add([]byte{binaryVersion, flagDictionary, 1, 1, 1})
+
+	dict := NewDictionary("a", "b")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var decoded VClock
+		if err := decoded.UnmarshalBinaryWith(data, dict); err != nil {
+			return
+		}
+
+		// A successfully decoded clock must survive a round trip
+		encoded, err := decoded.MarshalBinaryWith(dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again VClock
+		if err := again.UnmarshalBinaryWith(encoded, dict); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Compare(again, Equal) || len(decoded) != len(again) {
+			t.Fatalf("round trip changed the clock: %s != %s", decoded.ReturnVCString(), again.ReturnVCString())
+		}
+		reencoded, _ := again.MarshalBinaryWith(dict)
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("encoding is not deterministic")
+		}
+	})
+}
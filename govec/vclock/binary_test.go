@@ -0,0 +1,177 @@
+package vclock
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	n := New()
+	n.Set("a", 4)
+	n.Set("b", 1)
+	n.Set("c", 1<<40)
+	n.Set("", 0)
+
+	data, err := n.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded VClock
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	} else if !n.Compare(decoded, Equal) || len(n) != len(decoded) {
+		failComparison(t, "decoded not the same as encoded enc = %s | dec = %s", n, decoded)
+	}
+}
+
+func TestGobCompatibility(t *testing.T) {
+	// {"a":1, "b":300} encoded by Bytes before VClock implemented
+	// encoding.BinaryMarshaler
+	legacy, _ := hex.DecodeString("157f0401010656436c6f636b01ff8000010c010600000cff8000020161010162fe012c")
+	expected := VClock{"a": 1, "b": 300}
+
+	decoded, err := FromBytes(legacy)
+	if err != nil {
+		t.Fatal(err)
+	} else if !expected.Compare(decoded, Equal) || len(decoded) != len(expected) {
+		failComparison(t, "legacy clock not decoded expected = %s | dec = %s", expected, decoded)
+	}
+
+	// Clocks encoded by Bytes are still gob encoded maps
+	var m map[string]uint64
+	if err := gob.NewDecoder(bytes.NewReader(expected.Bytes())).Decode(&m); err != nil {
+		t.Fatal(err)
+	} else if !expected.Compare(VClock(m), Equal) || len(m) != len(expected) {
+		failComparison(t, "clock not encoded as a map expected = %s | dec = %s", expected, VClock(m))
+	}
+}
+
+func TestBinaryDictionary(t *testing.T) {
+	dict := NewDictionary("server", "client")
+	n := New()
+	n.Set("server", 3)
+	n.Set("client", 2)
+	n.Set("newcomer", 1)
+
+	data, err := n.MarshalBinaryWith(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := n.MarshalBinary()
+	if len(data) >= len(plain) {
+		t.Fatalf("dictionary encoding not smaller: %d >= %d bytes", len(data), len(plain))
+	}
+
+	var decoded VClock
+	if err := decoded.UnmarshalBinaryWith(data, dict); err != nil {
+		t.Fatal(err)
+	} else if !n.Compare(decoded, Equal) {
+		failComparison(t, "decoded not the same as encoded enc = %s | dec = %s", n, decoded)
+	}
+
+	if err := decoded.UnmarshalBinary(data); err == nil {
+		t.Fatalf("dictionary encoded clock decoded without a dictionary")
+	}
+	if err := decoded.UnmarshalBinaryWith(data, NewDictionary("server")); err == nil {
+		t.Fatalf("unknown dictionary entry not reported")
+	}
+}
+
+func TestBinaryMalformed(t *testing.T) {
+	valid, _ := VClock{"a": 1, "b": 300}.MarshalBinary()
+	tests := map[string][]byte{
+		"empty":         {},
+		"version":       {2, 0, 0},
+		"flags":         {binaryVersion, 0x80, 0},
+		"truncated":     valid[:len(valid)-1],
+		"trailing":      append(append([]byte(nil), valid...), 0),
+		"duplicate":     {binaryVersion, 0, 2, 1, 'a', 1, 1, 'a', 2},
+		"length":        {binaryVersion, 0, 1, 5, 'a', 1},
+		"huge count":    {binaryVersion, 0, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		"bad varint":    {binaryVersion, 0, 1, 1, 'a', 0xff},
+		"no dictionary": {binaryVersion, flagDictionary, 1, 1, 1},
+	}
+	for name, data := range tests {
+		var decoded VClock
+		if err := decoded.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: malformed clock decoded as %s", name, decoded.ReturnVCString())
+		}
+	}
+}
+
+func benchmarkClock(size int) VClock {
+	n := New()
+	for i := 0; i < size; i++ {
+		n.Set(fmt.Sprintf("process-%d", i), uint64(i*i))
+	}
+	return n
+}
+
+func BenchmarkEncoding(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		n := benchmarkClock(size)
+		ids := make([]string, 0, size)
+		for id := range n {
+			ids = append(ids, id)
+		}
+		dict := NewDictionary(ids...)
+
+		encoders := []struct {
+			name   string
+			encode func() ([]byte, error)
+		}{
+			{"binary", n.MarshalBinary},
+			{"binary-dictionary", func() ([]byte, error) { return n.MarshalBinaryWith(dict) }},
+			{"gob", func() ([]byte, error) { return gobEncode(n) }},
+			{"msgpack", func() ([]byte, error) { return msgpack.Marshal(map[string]uint64(n)) }},
+		}
+		for _, enc := range encoders {
+			b.Run(fmt.Sprintf("%s/%d", enc.name, size), func(b *testing.B) {
+				var data []byte
+				for i := 0; i < b.N; i++ {
+					data, _ = enc.encode()
+				}
+				b.ReportMetric(float64(len(data)), "bytes/clock")
+			})
+		}
+	}
+}
+
+func BenchmarkDecoding(b *testing.B) {
+	n := benchmarkClock(100)
+	binaryData, _ := n.MarshalBinary()
+	gobData, _ := gobEncode(n)
+	msgpackData, _ := msgpack.Marshal(map[string]uint64(n))
+
+	b.Run("binary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded VClock
+			decoded.UnmarshalBinary(binaryData)
+		}
+	})
+	b.Run("gob", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded map[string]uint64
+			gob.NewDecoder(bytes.NewReader(gobData)).Decode(&decoded)
+		}
+	})
+	b.Run("msgpack", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded map[string]uint64
+			msgpack.Unmarshal(msgpackData, &decoded)
+		}
+	})
+}
+
+// gobEncode encodes n with gob as a plain map
+func gobEncode(n VClock) ([]byte, error) {
+	b := new(bytes.Buffer)
+	err := gob.NewEncoder(b).Encode(map[string]uint64(n))
+	return b.Bytes(), err
+}
@@ -0,0 +1,116 @@
+package vclock
+
+// PrunePolicy decides which entries of a clock are kept by Prune.
+type PrunePolicy interface {
+	// Keep reports whether the entry of id, holding ticks, is kept
+	Keep(id string, ticks uint64) bool
+}
+
+// Prune removes the entries of the clock which policy does not keep,
+// such as the entries of processes which permanently left the system.
+//
+// Pruning loses information, and Compare treats a removed entry as
+// zero:
+//
+//   - Pruning the same ids from two clocks keeps every Ancestor,
+//     Descendant and Equal relationship between them, but clocks which
+//     were Concurrent only because of the removed entries become
+//     ordered or Equal. Events of a retired process are thus no longer
+//     told apart from the events which followed them.
+//   - Comparing a pruned clock with one which still holds the removed
+//     entries can report the pruned clock as an Ancestor of, or
+//     Concurrent with, a clock it descends from. An id must therefore
+//     only be pruned once every process has stopped using it and no
+//     message carrying it is in flight, or be pruned from every clock,
+//     including received ones, as GoLog.RetireProcess does.
+func (vc VClock) Prune(policy PrunePolicy) {
+	for id, ticks := range vc {
+		if !policy.Keep(id, ticks) {
+			delete(vc, id)
+		}
+	}
+}
+
+// Members is a PrunePolicy keeping only the entries of its members.
+type Members map[string]bool
+
+// NewMembers returns the Members policy keeping the entries of ids.
+func NewMembers(ids ...string) Members {
+	m := make(Members, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+// Keep reports whether id is a member.
+func (m Members) Keep(id string, ticks uint64) bool {
+	return m[id]
+}
+
+// Retired is a PrunePolicy removing the entries of retired processes.
+type Retired map[string]bool
+
+// NewRetired returns the Retired policy removing the entries of ids.
+func NewRetired(ids ...string) Retired {
+	r := make(Retired, len(ids))
+	for _, id := range ids {
+		r[id] = true
+	}
+	return r
+}
+
+// Keep reports whether id has not retired.
+func (r Retired) Keep(id string, ticks uint64) bool {
+	return !r[id]
+}
+
+// Stale is a PrunePolicy removing the entries which were not updated
+// during the last N local events of the process owning a clock. The
+// local events are counted by the entry of the owner, and updates are
+// recorded by calling Observe with the clock after every event. The
+// entry of the owner is always kept.
+type Stale struct {
+	owner string
+	n     uint64
+	// now is the entry of the owner at the latest observation
+	now uint64
+	// seen records the latest value observed for every entry and the
+	// local time at which it was first observed
+	seen map[string]staleEntry
+}
+
+type staleEntry struct {
+	ticks, at uint64
+}
+
+// NewStale returns a Stale policy for the clock of owner removing the
+// entries not updated in n local events.
+func NewStale(owner string, n uint64) *Stale {
+	return &Stale{owner: owner, n: n, seen: make(map[string]staleEntry)}
+}
+
+// Observe records the updates made to vc since the previous call.
+func (s *Stale) Observe(vc VClock) {
+	s.now = vc[s.owner]
+	for id, ticks := range vc {
+		if e, found := s.seen[id]; !found || e.ticks != ticks {
+			s.seen[id] = staleEntry{ticks: ticks, at: s.now}
+		}
+	}
+}
+
+// Keep reports whether the entry of id was updated during the last n
+// local events. Entries which were never observed are kept.
+func (s *Stale) Keep(id string, ticks uint64) bool {
+	if id == s.owner {
+		return true
+	}
+	e, found := s.seen[id]
+	if !found || e.ticks != ticks {
+		return true
+	}
+	// Removed entries stay recorded, so that they are removed again
+	// if merged back in without being updated
+	return s.now-e.at < s.n
+}
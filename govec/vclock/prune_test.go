@@ -0,0 +1,105 @@
+package vclock
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+func TestPruneMembers(t *testing.T) {
+
+	vc := VClock{"a": 1, "b": 2, "c": 3}
+	vc.Prune(NewMembers("a", "c", "d"))
+	expectClock(t, `{"a":1, "c":3}`, vc, "Prune: wrong members kept")
+
+	vc.Prune(NewRetired("a"))
+	expectClock(t, `{"c":3}`, vc, "Prune: retired entry kept")
+}
+
+func TestPruneStale(t *testing.T) {
+
+	policy := NewStale("a", 2)
+	vc := VClock{"a": 1, "b": 1, "c": 1}
+	policy.Observe(vc)
+	vc.Prune(policy)
+	expectClock(t, `{"a":1, "b":1, "c":1}`, vc, "Stale: fresh entries removed")
+
+	// b is updated, c is not
+	vc.Tick("a")
+	vc.Tick("b")
+	policy.Observe(vc)
+	vc.Prune(policy)
+	expectClock(t, `{"a":2, "b":2, "c":1}`, vc, "Stale: entry removed too early")
+
+	vc.Tick("a")
+	policy.Observe(vc)
+	vc.Prune(policy)
+	expectClock(t, `{"a":3, "b":2}`, vc, "Stale: stale entry kept")
+
+	// Merging the stale entry back does not revive it, while b turns
+	// stale in turn
+	vc.Merge(VClock{"c": 1})
+	vc.Tick("a")
+	policy.Observe(vc)
+	vc.Prune(policy)
+	expectClock(t, `{"a":4}`, vc, "Stale: stale entry revived")
+
+	// An update does
+	vc.Merge(VClock{"c": 2})
+	policy.Observe(vc)
+	vc.Prune(policy)
+	expectClock(t, `{"a":4, "c":2}`, vc, "Stale: updated entry removed")
+}
+
+func expectClock(t *testing.T, expected string, vc VClock, message string) {
+	if vc.ReturnVCString() != expected {
+		t.Fatalf("%s: expected %s, got %s", message, expected, vc.ReturnVCString())
+	}
+}
+
+func pruned(vc VClock, policy PrunePolicy) VClock {
+	cp := vc.Copy()
+	cp.Prune(policy)
+	return cp
+}
+
+func TestPruneKeepsOrder(t *testing.T) {
+	policy := NewRetired("d")
+	property := func(a, b VClock) bool {
+		pa, pb := pruned(a, policy), pruned(b, policy)
+		// Clocks pruned alike keep their order
+		for _, cond := range []Condition{Ancestor | Equal, Descendant | Equal} {
+			if b.Compare(a, cond) && !pb.Compare(pa, cond) {
+				return false
+			}
+		}
+		// and are only concurrent if they were before pruning
+		if Relation(pa, pb) == Concurrent && Relation(a, b) != Concurrent {
+			return false
+		}
+		// A pruned clock still happens before the clocks its
+		// original happened before
+		return !b.Compare(a, Ancestor|Equal) || b.Compare(pa, Ancestor|Equal)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneCaveats(t *testing.T) {
+
+	// Clocks only concurrent because of a retired process become ordered
+	a, b := VClock{"a": 1, "d": 2}, VClock{"a": 2, "d": 1}
+	if !b.Compare(a, Concurrent) {
+		t.Fatalf("Prune: clocks should be concurrent")
+	}
+	if !pruned(b, NewRetired("d")).Compare(pruned(a, NewRetired("d")), Ancestor) {
+		t.Fatalf("Prune: pruned clocks should be ordered")
+	}
+
+	// A pruned clock looks like an ancestor of an unpruned clock it
+	// descends from
+	c := VClock{"a": 2, "d": 2}
+	if !b.Compare(pruned(c, NewRetired("d")), Ancestor) {
+		t.Fatalf("Prune: pruned clock should look like an ancestor")
+	}
+}
@@ -0,0 +1,132 @@
+package vclock
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// randomClock generates small clocks over few ids, so that equal,
+// ordered and concurrent pairs are all likely, including entries
+// explicitly set to zero.
+func randomClock(r *rand.Rand) VClock {
+	vc := New()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if r.Intn(3) > 0 {
+			vc.Set(id, uint64(r.Intn(3)))
+		}
+	}
+	return vc
+}
+
+var quickConfig = &quick.Config{
+	MaxCount: 5000,
+	Values: func(args []reflect.Value, r *rand.Rand) {
+		for i := range args {
+			args[i] = reflect.ValueOf(randomClock(r))
+		}
+	},
+}
+
+// referenceLessOrEqual reports whether every entry of a is lower than
+// or equal to the matching entry of b, by brute force over all ids.
+func referenceLessOrEqual(a, b VClock) bool {
+	ids := map[string]bool{}
+	for id := range a {
+		ids[id] = true
+	}
+	for id := range b {
+		ids[id] = true
+	}
+	for id := range ids {
+		if a[id] > b[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// referenceRelation is the textbook definition of the relationship of
+// a to b
+func referenceRelation(a, b VClock) Condition {
+	aLE, bLE := referenceLessOrEqual(a, b), referenceLessOrEqual(b, a)
+	switch {
+	case aLE && bLE:
+		return Equal
+	case aLE:
+		return Ancestor
+	case bLE:
+		return Descendant
+	default:
+		return Concurrent
+	}
+}
+
+func TestRelationMatchesReference(t *testing.T) {
+	property := func(a, b VClock) bool {
+		return Relation(a, b) == referenceRelation(a, b)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelationIsAntisymmetric(t *testing.T) {
+	inverse := map[Condition]Condition{
+		Equal:      Equal,
+		Ancestor:   Descendant,
+		Descendant: Ancestor,
+		Concurrent: Concurrent,
+	}
+	property := func(a, b VClock) bool {
+		return inverse[Relation(a, b)] == Relation(b, a)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareMatchesRelation(t *testing.T) {
+	property := func(a, b VClock) bool {
+		rel := referenceRelation(b, a)
+		for cond := Condition(0); cond <= Equal|Ancestor|Descendant|Concurrent; cond++ {
+			expected := cond&rel != 0 || (rel == Equal && cond == Concurrent)
+			if a.Compare(b, cond) != expected {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeIsUpperBound(t *testing.T) {
+	property := func(a, b VClock) bool {
+		merged := a.Copy()
+		merged.Merge(b)
+		return !HappenedBefore(merged, a) && !HappenedBefore(merged, b) &&
+			!ConcurrentWith(merged, a) && !ConcurrentWith(merged, b)
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHappenedBefore(t *testing.T) {
+	n1 := VClock{"a": 1}
+	n2 := VClock{"a": 1, "b": 1}
+	n3 := VClock{"a": 2}
+
+	if !HappenedBefore(n1, n2) || HappenedBefore(n2, n1) {
+		failComparison(t, "Clock should have happened before: n1 = %s | n2 = %s", n1, n2)
+	}
+	if !ConcurrentWith(n2, n3) || !ConcurrentWith(n3, n2) {
+		failComparison(t, "Clocks not defined as concurrent: n1 = %s | n2 = %s", n2, n3)
+	}
+	if Relation(VClock{"a": 0}, New()) != Equal {
+		failComparison(t, "Zero entries not ignored: n1 = %s | n2 = %s", VClock{"a": 0}, New())
+	}
+}
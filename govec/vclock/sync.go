@@ -0,0 +1,58 @@
+package vclock
+
+import "sync"
+
+// SyncClock is a vector clock which is safe for concurrent use by
+// multiple goroutines. Its operations are protected by a lock, and the
+// clock is only ever handed out as a copy through Snapshot.
+type SyncClock struct {
+	mutex sync.RWMutex
+	vc    VClock
+}
+
+// NewSyncClock returns a SyncClock starting at a copy of initial,
+// which may be nil.
+func NewSyncClock(initial VClock) *SyncClock {
+	return &SyncClock{vc: initial.Copy()}
+}
+
+// Tick increments the clock value of id and returns a copy of the
+// resulting clock.
+func (c *SyncClock) Tick(id string) VClock {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.vc.Tick(id)
+	return c.vc.Copy()
+}
+
+// Set assigns a clock value to a clock index.
+func (c *SyncClock) Set(id string, ticks uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.vc.Set(id, ticks)
+}
+
+// Merge takes the max of all clock values in other and updates the
+// values of the clock, returning a copy of the resulting clock.
+func (c *SyncClock) Merge(other VClock) VClock {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.vc.Merge(other)
+	return c.vc.Copy()
+}
+
+// FindTicks returns the clock value for a given id, if a value is not
+// found false is returned.
+func (c *SyncClock) FindTicks(id string) (uint64, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.vc.FindTicks(id)
+}
+
+// Snapshot returns a copy of the clock, which the caller is free to
+// use and modify.
+func (c *SyncClock) Snapshot() VClock {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.vc.Copy()
+}
@@ -0,0 +1,57 @@
+package vclock
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncClockConcurrentUse(t *testing.T) {
+	c := NewSyncClock(VClock{"a": 1})
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Tick("a")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Merge(VClock{"b": uint64(j)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := c.Snapshot()
+				snapshot.Tick("c")
+				c.FindTicks("a")
+			}
+		}()
+	}
+	wg.Wait()
+
+	vc := c.Snapshot()
+	if vc["a"] != 801 || vc["b"] != 99 {
+		t.Fatalf("Concurrent updates lost: %s", vc.ReturnVCString())
+	}
+	if _, found := vc["c"]; found {
+		t.Fatalf("Snapshot modification leaked into the clock: %s", vc.ReturnVCString())
+	}
+}
+
+func TestSyncClockCopiesInitial(t *testing.T) {
+	initial := VClock{"a": 1}
+	c := NewSyncClock(initial)
+	c.Tick("a")
+
+	if initial["a"] != 1 {
+		t.Fatalf("Initial clock modified: %s", initial.ReturnVCString())
+	}
+	if n, _ := c.FindTicks("a"); n != 2 {
+		t.Fatalf("Tick value did not increment: %s", c.Snapshot().ReturnVCString())
+	}
+}
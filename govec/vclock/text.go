@@ -0,0 +1,217 @@
+package vclock
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// ParseError describes why a string could not be parsed as a vector
+// clock by Parse.
+type ParseError struct {
+	// Input is the string being parsed
+	Input string
+	// Offset is the byte offset in Input at which parsing failed
+	Offset int
+	// Msg describes the problem
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("vclock: invalid clock %q at offset %d: %s", e.Input, e.Offset, e.Msg)
+}
+
+// Parse parses the string encoding of a vector clock produced by
+// ReturnVCString, such as {"a":1, "b":2}, as found in GoVector logs.
+// Whitespace is allowed between tokens, but nothing may precede the
+// opening brace or follow the closing one. Ids may not contain quotes,
+// backslashes or control characters, and may not appear twice.
+func Parse(s string) (VClock, error) {
+	p := parser{input: s}
+	vc := New()
+
+	p.expect('{')
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+	} else {
+		for p.err == nil {
+			start := p.pos
+			id := p.parseID()
+			p.skipSpace()
+			p.expect(':')
+			p.skipSpace()
+			ticks := p.parseTicks()
+			if p.err != nil {
+				break
+			}
+			if _, found := vc[id]; found {
+				p.fail(start, fmt.Sprintf("id %q appears twice", id))
+				break
+			}
+			vc[id] = ticks
+
+			p.skipSpace()
+			if p.peek() == '}' {
+				p.pos++
+				break
+			}
+			p.expect(',')
+			p.skipSpace()
+		}
+	}
+	if p.err == nil && p.pos != len(s) {
+		p.fail(p.pos, "unexpected data after clock")
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	return vc, nil
+}
+
+// parser holds the state of Parse, recording the first error
+// encountered.
+type parser struct {
+	input string
+	pos   int
+	err   *ParseError
+}
+
+func (p *parser) fail(offset int, msg string) {
+	if p.err == nil {
+		p.err = &ParseError{Input: p.input, Offset: offset, Msg: msg}
+	}
+}
+
+// peek returns the next byte, or 0 at the end of the input
+func (p *parser) peek() byte {
+	if p.err != nil || p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) expect(c byte) {
+	if p.peek() != c {
+		if p.pos >= len(p.input) {
+			p.fail(p.pos, fmt.Sprintf("expected %q, found end of input", c))
+		} else {
+			p.fail(p.pos, fmt.Sprintf("expected %q, found %q", c, p.input[p.pos]))
+		}
+		return
+	}
+	p.pos++
+}
+
+func (p *parser) skipSpace() {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseID() string {
+	p.expect('"')
+	start := p.pos
+	for p.err == nil {
+		switch c := p.peek(); {
+		case p.pos >= len(p.input):
+			p.fail(p.pos, "unterminated id")
+		case c == '"':
+			id := p.input[start:p.pos]
+			p.pos++
+			if !utf8.ValidString(id) {
+				p.fail(start, "id is not valid UTF-8")
+			}
+			return id
+		case c == '\\' || c < 0x20:
+			p.fail(p.pos, fmt.Sprintf("invalid character %q in id", c))
+		default:
+			p.pos++
+		}
+	}
+	return ""
+}
+
+func (p *parser) parseTicks() uint64 {
+	start := p.pos
+	var ticks uint64
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		if p.pos > start && ticks == 0 {
+			p.fail(start, "clock value has a leading zero")
+			return 0
+		}
+		digit := uint64(c - '0')
+		if ticks > (^uint64(0)-digit)/10 {
+			p.fail(start, "clock value overflows uint64")
+			return 0
+		}
+		ticks = ticks*10 + digit
+		p.pos++
+	}
+	if p.err == nil && p.pos == start {
+		p.fail(start, "expected a clock value")
+	}
+	return ticks
+}
+
+// validID reports whether id can be encoded by ReturnVCString in a way
+// Parse decodes back to id.
+func validID(id string) bool {
+	if !utf8.ValidString(id) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c == '"' || c == '\\' || c < 0x20 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalText implements encoding.TextMarshaler, returning the clock
+// encoded as by ReturnVCString. An error is returned if an id could
+// not be parsed back by Parse.
+func (vc VClock) MarshalText() ([]byte, error) {
+	for id := range vc {
+		if !validID(id) {
+			return nil, fmt.Errorf("vclock: id %q cannot be encoded as text", id)
+		}
+	}
+	return []byte(vc.ReturnVCString()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing text with
+// Parse and replacing the content of the callee.
+func (vc *VClock) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*vc = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The encoding of a clock as
+// text is a valid JSON object, so it is returned as is.
+func (vc VClock) MarshalJSON() ([]byte, error) {
+	if vc == nil {
+		return []byte("null"), nil
+	}
+	return vc.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the JSON
+// objects produced by MarshalJSON. A JSON null leaves the callee
+// untouched.
+func (vc *VClock) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return vc.UnmarshalText(data)
+}
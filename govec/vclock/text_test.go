@@ -0,0 +1,110 @@
+package vclock
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	n := New()
+	n.Set("a", 1)
+	n.Set("b", 0)
+	n.Set("process 42", 18446744073709551615)
+
+	s := n.ReturnVCString()
+	parsed, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	} else if !n.Compare(parsed, Equal) || len(n) != len(parsed) {
+		failComparison(t, "parsed not the same as printed = %s | parsed = %s", n, parsed)
+	} else if parsed.ReturnVCString() != s {
+		t.Fatalf("Parse does not round trip: %s != %s", parsed.ReturnVCString(), s)
+	}
+
+	empty, err := Parse("{}")
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("Empty clock not parsed: %v", err)
+	}
+
+	spaced, err := Parse("{ \"a\" : 1 ,\n\"b\":2 }")
+	if err != nil || spaced["a"] != 1 || spaced["b"] != 2 {
+		t.Fatalf("Clock with whitespace not parsed: %v", err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := map[string]int{
+		``:                           0,
+		`{`:                          1,
+		` {}`:                        0,
+		`{}x`:                        2,
+		`{"a":1,}`:                   7,
+		`{"a":1 "b":2}`:              7,
+		`{"a":}`:                     5,
+		`{"a":-1}`:                   5,
+		`{"a":01}`:                   5,
+		`{"a":18446744073709551616}`: 5,
+		`{"a":1, "a":2}`:             8,
+		`{"a\"b":1}`:                 3,
+		`{"a":1`:                     6,
+		`{a:1}`:                      1,
+		"{\"\xff\":1}":               2,
+	}
+	for input, offset := range tests {
+		_, err := Parse(input)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected a ParseError, got %v", input, err)
+		} else if perr.Offset != offset {
+			t.Errorf("%q: error at offset %d, expected %d: %v", input, perr.Offset, offset, err)
+		}
+	}
+}
+
+func TestTextMarshaling(t *testing.T) {
+	n := New()
+	n.Set("a", 1)
+	n.Set("b", 2)
+
+	text, err := n.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	} else if string(text) != n.ReturnVCString() {
+		t.Fatalf("MarshalText %s not the same as ReturnVCString %s", text, n.ReturnVCString())
+	}
+
+	var decoded VClock
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	} else if !n.Compare(decoded, Equal) {
+		failComparison(t, "decoded not the same as encoded enc = %s | dec = %s", n, decoded)
+	}
+
+	n.Set("a\"b", 1)
+	if _, err := n.MarshalText(); err == nil {
+		t.Fatalf("Id with a quote encoded as text")
+	}
+}
+
+func TestJSONMarshaling(t *testing.T) {
+	type event struct {
+		Clock VClock
+	}
+	in := event{Clock: VClock{"a": 1, "b": 2}}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out event
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	} else if !in.Clock.Compare(out.Clock, Equal) {
+		failComparison(t, "decoded not the same as encoded enc = %s | dec = %s", in.Clock, out.Clock)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Clock":{"a":"1"}}`), &out); err == nil {
+		t.Fatalf("Malformed JSON clock decoded")
+	}
+}
@@ -23,6 +23,15 @@ const (
 
 // VClock are maps of string to uint64 where the string is the
 // id of the process, and the uint64 is the clock value
+//
+// Since VClock implements encoding.BinaryMarshaler and
+// encoding.TextMarshaler, encoders relying on these interfaces no
+// longer encode VClock values, struct fields included, as maps: gob and
+// msgpack v5 use the compact encoding of MarshalBinary. This breaks the
+// wire format of such data, which earlier versions of this package
+// cannot decode, nor decode from them. Converting fields to
+// map[string]uint64, see GetMap, keeps the map format. The messages of
+// govec, Bytes and FromBytes are not affected.
 type VClock map[string]uint64
 
 // FindTicks returns the clock value for a given id, if a value is not
@@ -90,7 +99,9 @@ func (vc VClock) Merge(other VClock) {
 func (vc VClock) Bytes() []byte {
 	b := new(bytes.Buffer)
 	enc := gob.NewEncoder(b)
-	err := enc.Encode(vc)
+	// Encode the plain map, as VClock implements
+	// encoding.BinaryMarshaler which gob would use instead
+	err := enc.Encode(map[string]uint64(vc))
 	if err != nil {
 		log.Fatal("Vector Clock Encode:", err)
 	}
@@ -101,10 +112,10 @@ func (vc VClock) Bytes() []byte {
 func FromBytes(data []byte) (vc VClock, err error) {
 	b := new(bytes.Buffer)
 	b.Write(data)
-	clock := New()
+	clock := map[string]uint64{}
 	dec := gob.NewDecoder(b)
 	err = dec.Decode(&clock)
-	return clock, err
+	return VClock(clock), err
 }
 
 // PrintVC prints the callee's vector clock to stdout
@@ -137,84 +148,65 @@ func (vc VClock) ReturnVCString() string {
 }
 
 // Compare takes another clock and determines if it is Equal,
-// Ancestor, Descendant, or Concurrent with the callee's clock.
+// Ancestor, Descendant, or Concurrent with the callee's clock, in
+// which case the matching bit of cond must be set for true to be
+// returned. Equal clocks are also considered Concurrent when cond is
+// exactly Concurrent. Entries missing from a clock count as zero. See
+// Relation to find which relationship holds in a single call.
 func (vc VClock) Compare(other VClock, cond Condition) bool {
-	var otherIs Condition
-	// Preliminary qualification based on length
-	if len(vc) > len(other) {
-		if cond&(Ancestor|Concurrent) == 0 {
-			return false
-		}
-		otherIs = Ancestor
-	} else if len(vc) < len(other) {
-		if cond&(Descendant|Concurrent) == 0 {
-			return false
-		}
-		otherIs = Descendant
-	} else {
-		otherIs = Equal
+	otherIs := Relation(other, vc)
+	// Equal clocks are concurrent
+	if otherIs == Equal && cond == Concurrent {
+		return true
 	}
+	return cond&otherIs != 0
+}
 
-	// Compare matching items
-	for id := range other {
-		if _, found := vc[id]; found {
-			if other[id] > vc[id] {
-				switch otherIs {
-				case Equal:
-					otherIs = Descendant
-					break
-				case Ancestor:
-					return cond&Concurrent != 0
-				}
-			} else if other[id] < vc[id] {
-				switch otherIs {
-				case Equal:
-					otherIs = Ancestor
-					break
-				case Descendant:
-					return cond&Concurrent != 0
-				}
-			}
-		} else {
-			if otherIs == Equal {
-				return cond&Concurrent != 0
-			} else if len(other) <= len(vc) {
-				return cond&Concurrent != 0
-			}
+// Relation returns the relationship of clock a to clock b: Ancestor
+// if a happened before b, Descendant if b happened before a, Equal if
+// they are identical and Concurrent otherwise. Entries missing from a
+// clock count as zero. Relation(a, b) is the condition cond for which
+// b.Compare(a, cond) holds.
+func Relation(a, b VClock) Condition {
+	// aBehind and bBehind record whether some entry of a, respectively
+	// b, is lower than the matching entry of the other clock
+	var aBehind, bBehind bool
+	for id, ticks := range a {
+		if ticks < b[id] {
+			aBehind = true
+		} else if ticks > b[id] {
+			bBehind = true
+		}
+		if aBehind && bBehind {
+			return Concurrent
 		}
 	}
-
-	for id := range vc {
-		if _, found := other[id]; found {
-			if other[id] > vc[id] {
-				switch otherIs {
-				case Equal:
-					otherIs = Descendant
-					break
-				case Ancestor:
-					return cond&Concurrent != 0
-				}
-			} else if other[id] < vc[id] {
-				switch otherIs {
-				case Equal:
-					otherIs = Ancestor
-					break
-				case Descendant:
-					return cond&Concurrent != 0
-				}
-			}
-		} else {
-			if otherIs == Equal {
-				return cond&Concurrent != 0
-			} else if len(vc) <= len(other) {
-				return cond&Concurrent != 0
-			}
+	for id, ticks := range b {
+		if _, found := a[id]; !found && ticks > 0 {
+			aBehind = true
+			break
 		}
 	}
 
-	// Equal clocks are concurrent
-	if otherIs == Equal && cond == Concurrent {
-		cond = Equal
+	switch {
+	case aBehind && bBehind:
+		return Concurrent
+	case aBehind:
+		return Ancestor
+	case bBehind:
+		return Descendant
+	default:
+		return Equal
 	}
-	return cond&otherIs != 0
+}
+
+// HappenedBefore reports whether clock a happened before clock b.
+func HappenedBefore(a, b VClock) bool {
+	return Relation(a, b) == Ancestor
+}
+
+// ConcurrentWith reports whether neither of clocks a and b happened
+// before the other and they are not equal.
+func ConcurrentWith(a, b VClock) bool {
+	return Relation(a, b) == Concurrent
 }
@@ -0,0 +1,69 @@
+package vgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+)
+
+//CodecName is the name of the codec carrying the vector clock with
+//every message of a stream, and the content-subtype of such streams,
+//whose content type is thus "application/grpc+vclock".
+const CodecName = "vclock"
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+//stampedMessage is a stream message along with the encoded vector
+//clock it carries. It is marshalled by codec as the length of the
+//clock as a varint, the clock and the message marshalled by the proto
+//codec.
+type stampedMessage struct {
+	clock []byte
+	msg   interface{}
+}
+
+//codec marshals stamped messages, and any other message as the proto
+//codec does.
+type codec struct{}
+
+var errFrame = errors.New("vgrpc: malformed stream message")
+
+//Marshal returns the wire format of v.
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	sm, ok := v.(*stampedMessage)
+	if !ok {
+		return encoding.GetCodec(proto.Name).Marshal(v)
+	}
+	msg, err := encoding.GetCodec(proto.Name).Marshal(sm.msg)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(sm.clock)+len(msg))
+	data = data[:binary.PutUvarint(data, uint64(len(sm.clock)))]
+	data = append(data, sm.clock...)
+	return append(data, msg...), nil
+}
+
+//Unmarshal parses the wire format into v.
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	sm, ok := v.(*stampedMessage)
+	if !ok {
+		return encoding.GetCodec(proto.Name).Unmarshal(data, v)
+	}
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)-n) {
+		return errFrame
+	}
+	data = data[n:]
+	sm.clock = append([]byte(nil), data[:size]...)
+	return encoding.GetCodec(proto.Name).Unmarshal(data[size:], sm.msg)
+}
+
+//Name returns CodecName.
+func (codec) Name() string {
+	return CodecName
+}
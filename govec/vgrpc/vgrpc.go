@@ -0,0 +1,322 @@
+//Package vgrpc provides gRPC interceptors which automatically log the
+//calls made from a gRPC client to a gRPC server.
+//
+//Unary calls carry the client's clock in the request metadata and the
+//server's clock in the response header, leaving the messages
+//untouched. gRPC only exchanges metadata when a stream starts and
+//ends, so streams carry the client's clock in the request metadata
+//when opened and the server's clock in the trailer when closed, while
+//every message of a stream carries the clock of its sender along with
+//it. Such streams are marshalled by the codec named CodecName, which
+//this package registers, so both ends must use vgrpc: a server using
+//StreamClientInterceptor's streams must import vgrpc and use
+//StreamServerInterceptor, or the streams fail, unlike unary calls
+//which a server without the interceptors serves as usual. Streams
+//opened by a client without the interceptors are served as usual,
+//without logging their messages, and so are streams opened with a
+//codec or content-subtype of their own, such as
+//grpc.CallContentSubtype("proto"), which the client interceptor
+//respects.
+package vgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//MetadataKey is the gRPC metadata key carrying the encoded vector
+//clock. Its -bin suffix makes gRPC transmit the value as binary.
+const MetadataKey = "vclock-bin"
+
+//UnaryClientInterceptor returns an interceptor which sends the
+//logger's clock with every unary call and merges the clock of the
+//server's response into it.
+func UnaryClientInterceptor(logger *govec.GoLog, options govec.GoLogOptions) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := send(ctx, logger, "Making gRPC call "+method, options)
+		if err != nil {
+			return err
+		}
+
+		var header, trailer metadata.MD
+		opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+		callErr := invoker(ctx, method, req, reply, cc, opts...)
+
+		err = receive(logger, "Received gRPC call response from server", header, trailer, options)
+		if err != nil && err != errNoClock && callErr == nil {
+			return err
+		}
+		return callErr
+	}
+}
+
+//UnaryServerInterceptor returns an interceptor which merges the
+//client's clock into the logger's clock before handling a unary call,
+//and sends the logger's clock back in the response header. The
+//context passed to the handler carries the merged clock, see
+//govec.ClockFromContext. Calls with a malformed clock fail with
+//codes.InvalidArgument.
+func UnaryServerInterceptor(logger *govec.GoLog, options govec.GoLogOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := receiveCtx(ctx, logger, "Received gRPC request "+info.FullMethod, options)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, handlerErr := handler(ctx, req)
+
+		if md, err := stamp(ctx, logger, "Sending response to gRPC request", options); err == nil {
+			grpc.SetHeader(ctx, md)
+		}
+		return resp, handlerErr
+	}
+}
+
+//StreamClientInterceptor returns an interceptor which sends the
+//logger's clock when a stream is opened and with every message, and
+//merges the clocks sent by the server with its messages and trailer.
+//The messages of streams opened with a codec or content-subtype of
+//their own do not carry clocks.
+func StreamClientInterceptor(logger *govec.GoLog, options govec.GoLogOptions) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := send(ctx, logger, "Opening gRPC stream "+method, options)
+		if err != nil {
+			return nil, err
+		}
+		stamped := !ownCodec(opts)
+		if stamped {
+			opts = append(opts, grpc.CallContentSubtype(CodecName))
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, logger: logger, options: options, stamped: stamped}, nil
+	}
+}
+
+//ownCodec reports whether opts select a codec or content-subtype.
+func ownCodec(opts []grpc.CallOption) bool {
+	for _, opt := range opts {
+		switch opt.(type) {
+		case grpc.ContentSubtypeCallOption, *grpc.ContentSubtypeCallOption,
+			grpc.ForceCodecCallOption, *grpc.ForceCodecCallOption,
+			grpc.CustomCodecCallOption, *grpc.CustomCodecCallOption:
+			return true
+		}
+	}
+	return false
+}
+
+//StreamServerInterceptor returns an interceptor which merges the
+//client's clock into the logger's clock when a stream is opened and
+//with every message, and sends the logger's clock with every message
+//and the trailer of the stream. The context of the stream carries the
+//clock merged when it was opened. Streams with a malformed clock fail
+//with codes.InvalidArgument.
+func StreamServerInterceptor(logger *govec.GoLog, options govec.GoLogOptions) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := receiveCtx(ss.Context(), logger, "Opened gRPC stream "+info.FullMethod, options)
+		if err != nil {
+			return err
+		}
+
+		wrapped := &serverStream{ServerStream: ss, ctx: ctx, logger: logger, options: options, stamped: stamped(ctx)}
+		handlerErr := handler(srv, wrapped)
+
+		if md, err := stamp(ctx, logger, "Closing gRPC stream "+info.FullMethod, options); err == nil {
+			ss.SetTrailer(md)
+		}
+		return handlerErr
+	}
+}
+
+//clientStream logs every message sent or received on a client stream.
+type clientStream struct {
+	grpc.ClientStream
+	logger  *govec.GoLog
+	options govec.GoLogOptions
+	//stamped is set if the messages of the stream carry clocks
+	stamped bool
+}
+
+//SendMsg logs a send event and sends m along with the logger's clock.
+func (s *clientStream) SendMsg(m interface{}) error {
+	if !s.stamped {
+		return s.ClientStream.SendMsg(m)
+	}
+	buf, err := stampMessage(s.logger, s.options)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return s.ClientStream.SendMsg(&stampedMessage{clock: buf, msg: m})
+}
+
+//RecvMsg receives a message into m and merges the clock it carries.
+//The clock of the server's trailer is merged once the stream ends.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	sm := &stampedMessage{msg: m}
+	var err error
+	if s.stamped {
+		err = s.ClientStream.RecvMsg(sm)
+	} else {
+		err = s.ClientStream.RecvMsg(m)
+	}
+	if err == io.EOF {
+		receive(s.logger, "Received gRPC stream trailer", nil, s.Trailer(), s.options)
+		return err
+	} else if err != nil || !s.stamped {
+		return err
+	}
+	if err := receiveMessage(s.logger, sm.clock, s.options); err != nil {
+		return status.Error(codes.DataLoss, err.Error())
+	}
+	return nil
+}
+
+//serverStream logs every message sent or received on a server stream
+//opened with the stream client interceptor.
+type serverStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	logger  *govec.GoLog
+	options govec.GoLogOptions
+	//stamped is set if the messages of the stream carry clocks
+	stamped bool
+}
+
+//Context returns the context of the stream, carrying the merged clock.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+//SendMsg logs a send event and sends m along with the logger's clock.
+func (s *serverStream) SendMsg(m interface{}) error {
+	if !s.stamped {
+		return s.ServerStream.SendMsg(m)
+	}
+	buf, err := stampMessage(s.logger, s.options)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return s.ServerStream.SendMsg(&stampedMessage{clock: buf, msg: m})
+}
+
+//RecvMsg receives a message into m and merges the clock it carries.
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if !s.stamped {
+		return s.ServerStream.RecvMsg(m)
+	}
+	sm := &stampedMessage{msg: m}
+	if err := s.ServerStream.RecvMsg(sm); err != nil {
+		return err
+	}
+	if err := receiveMessage(s.logger, sm.clock, s.options); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+//stamped reports whether the messages of the stream of ctx are
+//marshalled by the codec named CodecName.
+func stamped(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, contentType := range md.Get("content-type") {
+		if strings.ToLower(contentType) == "application/grpc+"+CodecName {
+			return true
+		}
+	}
+	return false
+}
+
+//stampMessage ticks the logger's clock, logs the send event of a
+//stream message and returns the encoded clock, nil if the event is
+//not logged.
+func stampMessage(logger *govec.GoLog, options govec.GoLogOptions) ([]byte, error) {
+	buf, err := logger.PrepareSendE("Sending gRPC stream message", nil, options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return nil, err
+	}
+	return buf, nil
+}
+
+//receiveMessage merges the clock carried by a stream message into the
+//logger's clock. A message without clock was sent by an event which
+//was not logged, and is not logged either.
+func receiveMessage(logger *govec.GoLog, clock []byte, options govec.GoLogOptions) error {
+	if len(clock) == 0 {
+		return nil
+	}
+	err := logger.UnpackReceiveE("Received gRPC stream message", clock, nil, options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return err
+	}
+	return nil
+}
+
+//errNoClock is returned by receive when no metadata carries a clock
+var errNoClock = errors.New("vgrpc: no vector clock in metadata")
+
+//stamp ticks the logger's clock, logs a send event and returns
+//metadata carrying the encoded clock.
+func stamp(ctx context.Context, logger *govec.GoLog, mesg string, options govec.GoLogOptions) (metadata.MD, error) {
+	buf, err := logger.PrepareSendCtx(ctx, mesg, nil, options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return nil, err
+	}
+	if buf == nil {
+		return nil, errNoClock
+	}
+	return metadata.Pairs(MetadataKey, string(buf)), nil
+}
+
+//send stamps an outgoing context with the logger's clock.
+func send(ctx context.Context, logger *govec.GoLog, mesg string, options govec.GoLogOptions) (context.Context, error) {
+	md, err := stamp(ctx, logger, mesg, options)
+	if err == errNoClock {
+		return ctx, nil
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, md.Get(MetadataKey)[0]), nil
+}
+
+//receive merges the clock carried by header, or else by trailer, into
+//the logger's clock.
+func receive(logger *govec.GoLog, mesg string, header, trailer metadata.MD, options govec.GoLogOptions) error {
+	values := header.Get(MetadataKey)
+	if len(values) == 0 {
+		values = trailer.Get(MetadataKey)
+	}
+	if len(values) == 0 {
+		return errNoClock
+	}
+	err := logger.UnpackReceiveE(mesg, []byte(values[len(values)-1]), nil, options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return status.Error(codes.DataLoss, err.Error())
+	}
+	return nil
+}
+
+//receiveCtx merges the clock of an incoming call into the logger's
+//clock and returns a context carrying the merged clock.
+func receiveCtx(ctx context.Context, logger *govec.GoLog, mesg string, options govec.GoLogOptions) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+	ctx, err := logger.UnpackReceiveCtx(ctx, mesg, []byte(values[len(values)-1]), nil, options)
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return ctx, nil
+}
@@ -0,0 +1,209 @@
+package vgrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/DistributedClocks/GoVector/govec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//collectDesc describes a client streaming service counting the
+//requests it receives
+var collectDesc = grpc.ServiceDesc{
+	ServiceName: "vgrpc.Collector",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Collect",
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			services := ""
+			for {
+				var req healthpb.HealthCheckRequest
+				err := stream.RecvMsg(&req)
+				if err == io.EOF {
+					return stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+				} else if err != nil {
+					return err
+				}
+				services += req.Service
+				if services == "abc" {
+					stream.SetTrailer(metadata.Pairs("services", services))
+				}
+			}
+		},
+	}},
+}
+
+func startServer(t *testing.T, serverlogger, clientlogger *govec.GoLog) healthpb.HealthClient {
+	return healthpb.NewHealthClient(dialServer(t, serverlogger, clientlogger))
+}
+
+func dialServer(t *testing.T, serverlogger, clientlogger *govec.GoLog) *grpc.ClientConn {
+	options := govec.GetDefaultLogOptions()
+
+	l := bufconn.Listen(1 << 16)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverlogger, options)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverlogger, options)),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	server.RegisterService(&collectDesc, struct{}{})
+	go server.Serve(l)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientlogger, options)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientlogger, options)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestUnary(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	client := startServer(t, serverlogger, clientlogger)
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	server_ticks, _ := serverlogger.GetCurrentVC().FindTicks("server")
+	client_vc := clientlogger.GetCurrentVC()
+	client_ticks, _ := client_vc.FindTicks("client")
+	merged_ticks, _ := client_vc.FindTicks("server")
+
+	AssertEquals(t, uint64(3), server_ticks, "Server Clock value not incremented")
+	AssertEquals(t, uint64(3), client_ticks, "Client Clock value not incremented")
+	AssertEquals(t, uint64(3), merged_ticks, "Server clock not merged into client clock")
+}
+
+func TestServerStream(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	client := startServer(t, serverlogger, clientlogger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	client_vc := clientlogger.GetCurrentVC()
+	client_ticks, _ := client_vc.FindTicks("client")
+	merged_ticks, _ := client_vc.FindTicks("server")
+
+	// The request message is stamped too
+	AssertEquals(t, uint64(4), client_ticks, "Client Clock value not incremented")
+	AssertEquals(t, uint64(4), merged_ticks, "Server message clock not merged into client clock")
+}
+
+func TestClientStream(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	conn := dialServer(t, serverlogger, clientlogger)
+
+	stream, err := conn.NewStream(context.Background(), &collectDesc.Streams[0], "/vgrpc.Collector/Collect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"a", "b", "c"} {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: service}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var resp healthpb.HealthCheckResponse
+	if err := stream.RecvMsg(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every message carries the clock of its sender
+	server_vc := serverlogger.GetCurrentVC()
+	sent_ticks, _ := server_vc.FindTicks("client")
+	client_vc := clientlogger.GetCurrentVC()
+	client_ticks, _ := client_vc.FindTicks("client")
+	merged_ticks, _ := client_vc.FindTicks("server")
+
+	AssertEquals(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "Wrong stream response")
+	AssertEquals(t, "abc", stream.Trailer().Get("services")[0], "Wrong stream messages")
+	AssertEquals(t, uint64(5), sent_ticks, "Client message clocks not merged into server clock")
+	AssertEquals(t, uint64(6), client_ticks, "Client Clock value not incremented")
+	AssertEquals(t, uint64(6), merged_ticks, "Server message clock not merged into client clock")
+}
+
+func TestOwnContentSubtype(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	conn := dialServer(t, serverlogger, clientlogger)
+
+	stream, err := conn.NewStream(context.Background(), &collectDesc.Streams[0], "/vgrpc.Collector/Collect", grpc.CallContentSubtype("proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"a", "b", "c"} {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: service}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var resp healthpb.HealthCheckResponse
+	if err := stream.RecvMsg(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the clock sent when the stream is opened is merged
+	sent_ticks, _ := serverlogger.GetCurrentVC().FindTicks("client")
+
+	AssertEquals(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "Wrong stream response")
+	AssertEquals(t, "abc", stream.Trailer().Get("services")[0], "Wrong stream messages")
+	AssertEquals(t, uint64(2), sent_ticks, "Client message clocks sent with a content-subtype of its own")
+}
+
+func TestMalformedMetadata(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+	interceptor := UnaryServerInterceptor(serverlogger, options)
+
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "not a clock"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test"}, handler)
+
+	server_ticks, _ := serverlogger.GetCurrentVC().FindTicks("server")
+	AssertEquals(t, codes.InvalidArgument, status.Code(err), "Malformed clock not rejected")
+	AssertEquals(t, false, called, "Handler called with a malformed clock")
+	AssertEquals(t, uint64(1), server_ticks, "Server Clock value incremented")
+}
+
+func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
+	if expected != actual {
+		t.Fatalf(message+"Expected: %v, Actual: %v", expected, actual)
+	}
+}
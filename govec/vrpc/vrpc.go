@@ -4,7 +4,9 @@ package vrpc
 
 import (
 	"bufio"
+	"context"
 	"encoding/gob"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -21,7 +23,46 @@ func RPCDial(network, address string, logger *govec.GoLog, options govec.GoLogOp
 	if err != nil {
 		return nil, err
 	}
-	return rpc.NewClientWithCodec(newClientCodec(conn, logger, options)), err
+	return rpc.NewClientWithCodec(newClientCodec(context.Background(), conn, logger, options)), err
+}
+
+//RPCDialContext is like RPCDial, but the vector clock carried by ctx,
+//if any, is merged into the logger's clock before every call made by
+//the returned client. See govec.ContextWithClock.
+func RPCDialContext(ctx context.Context, network, address string, logger *govec.GoLog, options govec.GoLogOptions) (*rpc.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewClientWithCodec(newClientCodec(ctx, conn, logger, options)), err
+}
+
+//CallContext invokes the named function of client like client.Call,
+//but merges the vector clock carried by ctx, if any, into the logger's
+//clock before making this call only, overriding the context the
+//client was created with. The client must have been created by this
+//package. See govec.ContextWithClock.
+func CallContext(ctx context.Context, client *rpc.Client, serviceMethod string, args interface{}, reply interface{}) error {
+	return client.Call(serviceMethod, &callArgs{ctx: ctx, args: args}, reply)
+}
+
+//GoBroadcast invokes the named function of client asynchronously like
+//client.Go, sending the arguments as a message of the broadcast of
+//sender, which carries the clock of its single send event instead of
+//logging an event of its own. The client must have been created by
+//this package. See govec.GoLog.Broadcast.
+func GoBroadcast(sender govec.BroadcastSender, client *rpc.Client, serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
+	return client.Go(serviceMethod, &callArgs{args: args, sender: sender}, reply, done)
+}
+
+//callArgs carries the context of a call made with CallContext, or the
+//sender of a call made with GoBroadcast, along with its arguments,
+//down to RPCClientCodec.WriteRequest
+type callArgs struct {
+	ctx    context.Context
+	args   interface{}
+	sender govec.BroadcastSender
 }
 
 //ServeRPCConn is a convenience function that accepts connections for a
@@ -49,6 +90,7 @@ type RPCClientCodec struct {
 	EncBuf  *bufio.Writer
 	Logger  *govec.GoLog
 	Options govec.GoLogOptions
+	Context context.Context
 }
 
 //RPCServerCodec is an extension of the default rpc codec which uses a
@@ -66,22 +108,51 @@ type RPCServerCodec struct {
 
 //NewClient returs an rpc.Client insturmented with vector clocks.
 func NewClient(conn io.ReadWriteCloser, logger *govec.GoLog, options govec.GoLogOptions) *rpc.Client {
-	return rpc.NewClientWithCodec(newClientCodec(conn, logger, options))
+	return rpc.NewClientWithCodec(newClientCodec(context.Background(), conn, logger, options))
 }
 
-func newClientCodec(conn io.ReadWriteCloser, logger *govec.GoLog, options govec.GoLogOptions) rpc.ClientCodec {
+//NewClientWithContext returns an rpc.Client instrumented with vector
+//clocks which merges the vector clock carried by ctx into the logger's
+//clock before every call.
+func NewClientWithContext(ctx context.Context, conn io.ReadWriteCloser, logger *govec.GoLog, options govec.GoLogOptions) *rpc.Client {
+	return rpc.NewClientWithCodec(newClientCodec(ctx, conn, logger, options))
+}
+
+func newClientCodec(ctx context.Context, conn io.ReadWriteCloser, logger *govec.GoLog, options govec.GoLogOptions) rpc.ClientCodec {
 	encBuf := bufio.NewWriter(conn)
-	return &RPCClientCodec{conn, gob.NewDecoder(conn), gob.NewEncoder(encBuf), encBuf, logger, options}
+	return &RPCClientCodec{conn, gob.NewDecoder(conn), gob.NewEncoder(encBuf), encBuf, logger, options, ctx}
 }
 
 //WriteRequest marshalls and sends an rpc request, and it's associated
-//parameters to an RPC server
+//parameters to an RPC server. The clock of the context of the call,
+//see CallContext, or else of the codec, is merged first. The
+//parameters of a call made with GoBroadcast are encoded by its
+//BroadcastSender
 func (c *RPCClientCodec) WriteRequest(req *rpc.Request, param interface{}) (err error) {
-	if err = c.Enc.Encode(req); err != nil {
+	ctx := c.Context
+	var sender govec.BroadcastSender
+	if call, ok := param.(*callArgs); ok {
+		param, sender = call.args, call.sender
+		if call.ctx != nil {
+			ctx = call.ctx
+		}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var buf []byte
+	if sender != nil {
+		buf, err = sender.PrepareSend(param)
+	} else {
+		buf, err = c.Logger.PrepareSendCtx(ctx, "Making RPC call", param, c.Options)
+	}
+	if err != nil && !errors.Is(err, govec.ErrLogWrite) {
 		return
 	}
 
-	buf := c.Logger.PrepareSend("Making RPC call", param, c.Options)
+	if err = c.Enc.Encode(req); err != nil {
+		return
+	}
 	if err = c.Enc.Encode(buf); err != nil {
 		return
 	}
@@ -1,6 +1,7 @@
 package vrpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/DistributedClocks/GoVector/govec"
+	"github.com/DistributedClocks/GoVector/govec/vclock"
 )
 
 var done chan int = make(chan int, 1)
@@ -94,6 +96,103 @@ func TestRPC(t *testing.T) {
 	AssertEquals(t, uint64(5), client_ticks, "Client Clock value not incremented")
 }
 
+func TestRPCContext(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+
+	server := rpc.NewServer()
+	server.Register(new(Arith))
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(newServerCodec(serverConn, serverlogger, options))
+
+	ctx := govec.ContextWithClock(context.Background(), vclock.VClock{"upstream": 4})
+	client := NewClientWithContext(ctx, clientConn, clientlogger, options)
+	defer client.Close()
+
+	var result int
+	if err := client.Call("Arith.Multiply", Args{5, 6}, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	upstream_ticks, _ := serverlogger.GetCurrentVC().FindTicks("upstream")
+	AssertEquals(t, 30, result, "Wrong RPC result")
+	AssertEquals(t, uint64(4), upstream_ticks, "Context clock not propagated")
+}
+
+func TestRPCCallContext(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+
+	server := rpc.NewServer()
+	server.Register(new(Arith))
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(newServerCodec(serverConn, serverlogger, options))
+
+	clientCtx := govec.ContextWithClock(context.Background(), vclock.VClock{"upstream": 4})
+	client := NewClientWithContext(clientCtx, clientConn, clientlogger, options)
+	defer client.Close()
+
+	var result int
+	ctx := govec.ContextWithClock(context.Background(), vclock.VClock{"caller": 3})
+	if err := CallContext(ctx, client, "Arith.Multiply", Args{5, 6}, &result); err != nil {
+		t.Fatal(err)
+	}
+	caller_ticks, _ := serverlogger.GetCurrentVC().FindTicks("caller")
+	_, found := serverlogger.GetCurrentVC().FindTicks("upstream")
+	AssertEquals(t, 30, result, "Wrong RPC result")
+	AssertEquals(t, uint64(3), caller_ticks, "Call context clock not propagated")
+	AssertEquals(t, false, found, "Client context clock used for a CallContext call")
+
+	// Plain calls still use the context of the client
+	if err := client.Call("Arith.Multiply", Args{2, 3}, &result); err != nil {
+		t.Fatal(err)
+	}
+	upstream_ticks, _ := serverlogger.GetCurrentVC().FindTicks("upstream")
+	AssertEquals(t, uint64(4), upstream_ticks, "Context clock not propagated")
+}
+
+func TestRPCBroadcast(t *testing.T) {
+	serverlogger := govec.InitGoVector("server", "serverlogfile", govec.GetDefaultConfig())
+	clientlogger := govec.InitGoVector("client", "clientlogfile", govec.GetDefaultConfig())
+	options := govec.GetDefaultLogOptions()
+
+	server := rpc.NewServer()
+	server.Register(new(Arith))
+	var clients [2]*rpc.Client
+	for i := range clients {
+		clientConn, serverConn := net.Pipe()
+		go server.ServeCodec(newServerCodec(serverConn, serverlogger, options))
+		clients[i] = NewClient(clientConn, clientlogger, options)
+		defer clients[i].Close()
+	}
+
+	var calls [2]*rpc.Call
+	var results [2]int
+	err := clientlogger.Broadcast("Broadcasting via RPC", options, func(sender govec.BroadcastSender) error {
+		for i, client := range clients {
+			calls[i] = GoBroadcast(sender, client, "Arith.Multiply", Args{i, i + 1}, &results[i], nil)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range calls {
+		if call = <-call.Done; call.Error != nil {
+			t.Fatal(call.Error)
+		}
+	}
+
+	// Both calls carry the clock of the single send event
+	client_ticks, _ := clientlogger.GetCurrentVC().FindTicks("client")
+	sent_ticks, _ := serverlogger.GetCurrentVC().FindTicks("client")
+	AssertEquals(t, 2, results[1], "Wrong RPC result")
+	AssertEquals(t, uint64(4), client_ticks, "Client Clock value not incremented")
+	AssertEquals(t, uint64(2), sent_ticks, "Broadcast clock not sent")
+}
+
 func AssertEquals(t *testing.T, expected interface{}, actual interface{}, message string) {
 	if expected != actual {
 		t.Fatalf(message+"Expected: %s, Actual: %s", expected, actual)